@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"trendyolcase/pkg/service"
+)
+
+/*
+Runs 'trendyolcase backfill [-apply]'. The stored mappings of older converter versions are recomputed with the
+current converters and rules (RULES_PATH, PRODUCT_CATALOG_PATH) and the differences are printed.
+Nothing is written unless -apply is given.
+A running service serves rewritten mappings from its cache until LINK_CACHE_TTL, send it SIGHUP to drop the cache.
+*/
+
+func (a *App) backfill(args []string) {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	apply := flags.Bool("apply", false, "write the recomputed mappings")
+	_ = flags.Parse(args)
+
+	repository, ok := a.Repository.(service.BackfillRepository)
+	if !ok {
+		log.Fatalf("REPOSITORY doesn't support backfill.")
+	}
+	converterService := initConverterService(a.Repository, a.Catalog, a.Rules)
+	report, err := converterService.Backfill(repository, *apply, nil)
+	printBackfillReport(os.Stdout, report, *apply)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printBackfillReport(out io.Writer, report service.BackfillReport, applied bool) {
+	for _, change := range report.Changes {
+		fmt.Fprintf(out, "- %s -> %s\n+ %s -> %s\n", change.Stored.WebUrl, change.Stored.Deeplink, change.Recomputed.WebUrl, change.Recomputed.Deeplink)
+	}
+	for _, failed := range report.Failed {
+		fmt.Fprintf(out, "! %s -> %s: %s\n", failed.Stored.WebUrl, failed.Stored.Deeplink, failed.Err)
+	}
+	state := "would change"
+	if applied {
+		state = "changed"
+	}
+	fmt.Fprintf(out, "Converter version %s: %d stale mappings checked, %d unchanged, %d %s, %d failed.\n",
+		report.ConverterVersion, report.Checked, report.Unchanged, len(report.Changes), state, len(report.Failed))
+	if !applied && len(report.Changes) > 0 {
+		fmt.Fprintln(out, "Run with -apply to write them.")
+	}
+	if applied && len(report.Changes) > 0 {
+		fmt.Fprintln(out, "Send SIGHUP to running services to drop their cached lookups.")
+	}
+}
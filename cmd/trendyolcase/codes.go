@@ -0,0 +1,23 @@
+package main
+
+import (
+	"log"
+	"trendyolcase/pkg/service"
+)
+
+/*
+Runs 'trendyolcase codes'. Mappings without a short code, never shortened or stored before codes existed, get a generated one.
+*/
+
+func (a *App) assignCodes() {
+	repository, ok := a.Repository.(service.CodeRepository)
+	if !ok {
+		log.Fatalf("REPOSITORY doesn't support assigning codes.")
+	}
+	converterService := initConverterService(a.Repository, a.Catalog, a.Rules)
+	n, err := converterService.AssignMissingCodes(repository)
+	log.Printf("%d mappings got a code.", n)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"trendyolcase/pkg/service"
+)
+
+/*
+ConvertResult is one converted input of the convert subcommand.
+*/
+
+type ConvertResult struct {
+	Input   string               `json:"input"`
+	Output  string               `json:"output,omitempty"`
+	Error   string               `json:"error,omitempty"`
+	Explain *service.Explanation `json:"explain,omitempty"`
+}
+
+/*
+Runs 'trendyolcase convert [-to auto|deeplink|weburl] [-format text|csv|json] [-csv file -column name] [-explain] [-strict] [link...]'.
+Links are taken from the arguments, from a CSV column or from stdin (one per line). Nothing is stored and no .env
+or database is needed, RULES_PATH and PRODUCT_CATALOG_PATH can be given with -rules and -catalog.
+With -explain the matched page type, the extracted fields and the reason of a fallback are written too.
+With -strict malformed links of a recognized page type fail with the fallback reason instead of converting to the home page.
+Returns the exit status: 0 when every input was converted, 1 when an input failed validation, 2 for usage errors.
+*/
+
+func runConvert(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	to := flags.String("to", "auto", "auto (web URLs to deeplinks and the others to web URLs), deeplink or weburl")
+	format := flags.String("format", "text", "output format, text, csv or json")
+	csvPath := flags.String("csv", "", "CSV file to read the links from, '-' for stdin")
+	column := flags.String("column", "", "CSV column name or 0-based index holding the links")
+	rulesPath := flags.String("rules", "", "conversion rules file, the embedded rules by default")
+	catalogPath := flags.String("catalog", "", "optional product catalog")
+	explain := flags.Bool("explain", false, "also write the page type, the extracted fields and the fallback reason")
+	strict := flags.Bool("strict", false, "fail malformed links of a recognized page type instead of converting them to the home page")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *to != "auto" && *to != "deeplink" && *to != "weburl" {
+		fmt.Fprintln(stderr, "To should be auto, deeplink or weburl.")
+		return 2
+	}
+	if *format != "text" && *format != "csv" && *format != "json" {
+		fmt.Fprintln(stderr, "Format should be text, csv or json.")
+		return 2
+	}
+	if (*csvPath == "") != (*column == "") {
+		fmt.Fprintln(stderr, "CSV file and column should be given together.")
+		return 2
+	}
+
+	rules, err := ruleSetOrDefault(*rulesPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	converterService := service.ConverterService{ActiveRules: service.NewActiveRuleSet(rules)}
+	if *catalogPath != "" {
+		if converterService.Catalog, err = service.NewFileProductCatalog(*catalogPath); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+
+	var inputs []string
+	switch {
+	case flags.NArg() > 0:
+		inputs = flags.Args()
+	case *csvPath != "":
+		inputs, err = readCSVColumn(*csvPath, *column, stdin)
+	default:
+		inputs, err = readLines(stdin)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	status := 0
+	results := make([]ConvertResult, len(inputs))
+	for i, input := range inputs {
+		results[i] = convertLink(&converterService, input, *to, *explain, *strict)
+		if results[i].Error != "" {
+			status = 1
+		}
+	}
+	if err := writeConvertResults(stdout, stderr, *format, results); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	return status
+}
+
+/*
+Converts like the API does, attribution parameters are taken out before the conversion and put back on the output.
+*/
+
+func convertLink(converterService *service.ConverterService, input string, to string, explain bool, strict bool) ConvertResult {
+	result := ConvertResult{Input: input}
+	toDeepLink := to == "deeplink"
+	if to == "auto" {
+		toDeepLink = strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://")
+	}
+	requestLink, attribution := service.ExtractAttribution(input)
+	var explanation service.Explanation
+	var err error
+	if toDeepLink {
+		explanation, err = converterService.ExplainDeepLink(requestLink)
+	} else {
+		explanation, err = converterService.ExplainWebURL(requestLink)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if strict && explanation.Malformed() {
+		result.Error = string(explanation.ReasonCode) + ": " + explanation.Reason
+		return result
+	}
+	result.Output = attribution.AppendTo(explanation.Output)
+	if explain {
+		result.Explain = &explanation
+	}
+	return result
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+func readCSVColumn(path string, column string, stdin io.Reader) ([]string, error) {
+	r := stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	index, err := strconv.Atoi(column)
+	if err != nil {
+		// A column name, the first row is the header.
+		index = -1
+		for i, name := range records[0] {
+			if strings.TrimSpace(name) == column {
+				index = i
+			}
+		}
+		if index == -1 {
+			return nil, fmt.Errorf("CSV file has no %s column.", column)
+		}
+		records = records[1:]
+	}
+	var links []string
+	for i, record := range records {
+		if index < 0 || index >= len(record) {
+			return nil, fmt.Errorf("Row %d of the CSV file has no column %d.", i+1, index)
+		}
+		if link := strings.TrimSpace(record[index]); link != "" {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+/*
+text writes one output per line, a failed input gets an empty line so the lines match the inputs and its error goes to stderr.
+csv and json write every input with its output or error.
+Explanations are tab-separated columns after the output in text, extra columns in csv and an object in json.
+*/
+
+func writeConvertResults(stdout io.Writer, stderr io.Writer, format string, results []ConvertResult) error {
+	switch format {
+	case "csv":
+		writer := csv.NewWriter(stdout)
+		explained := false
+		for _, result := range results {
+			explained = explained || result.Explain != nil
+		}
+		header := []string{"input", "output", "error"}
+		if explained {
+			header = append(header, "page_type", "fields", "fallback_reason")
+		}
+		_ = writer.Write(header)
+		for _, result := range results {
+			record := []string{result.Input, result.Output, result.Error}
+			if explained {
+				record = append(record, explanationColumns(result.Explain)...)
+			}
+			_ = writer.Write(record)
+		}
+		writer.Flush()
+		return writer.Error()
+	case "json":
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if results == nil {
+			results = []ConvertResult{}
+		}
+		return encoder.Encode(results)
+	default:
+		for _, result := range results {
+			if result.Error != "" {
+				fmt.Fprintf(stderr, "%s: %s\n", result.Input, result.Error)
+			}
+			if result.Explain == nil {
+				fmt.Fprintln(stdout, result.Output)
+				continue
+			}
+			fmt.Fprintln(stdout, strings.Join(append([]string{result.Output}, explanationColumns(result.Explain)...), "\t"))
+		}
+		return nil
+	}
+}
+
+/*
+Page type, fields as 'name=value' pairs sorted by name, and fallback reason. A failed input has empty columns.
+*/
+
+func explanationColumns(explanation *service.Explanation) []string {
+	if explanation == nil {
+		return []string{"", "", ""}
+	}
+	fields := make([]string, 0, len(explanation.Fields))
+	for name, value := range explanation.Fields {
+		fields = append(fields, name+"="+value)
+	}
+	sort.Strings(fields)
+	return []string{explanation.PageType, strings.Join(fields, " "), explanation.Reason}
+}
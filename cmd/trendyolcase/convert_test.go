@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestRunConvert(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		args           []string
+		stdin          string
+		expectedStatus int
+		expectedOutput string
+	}{
+		{[]string{"https://www.trendyol.com/sr?q=saat&utm_source=Feed", "ty://?Page=Basket"}, "", 0,
+			"ty://?Page=Search&Query=saat&utm_source=feed\nhttps://www.trendyol.com/sepet\n"},
+		{[]string{"-to", "deeplink"}, "https://www.trendyol.com/sepet\n\nnot a url\n", 1, "ty://?Page=Basket\n\n"},
+		{[]string{"-format", "csv", "-csv", "-", "-column", "long_url"}, "short_url,long_url\nx,https://www.trendyol.com/sepet\n", 0,
+			"input,output,error\nhttps://www.trendyol.com/sepet,ty://?Page=Basket,\n"},
+		{[]string{"-format", "json", "-to", "weburl", "ty://?Page=Basket"}, "", 0,
+			"[\n  {\n    \"input\": \"ty://?Page=Basket\",\n    \"output\": \"https://www.trendyol.com/sepet\"\n  }\n]\n"},
+		{[]string{"-explain", "https://www.trendyol.com/sr?q=saat&sort=price", "ty://?Page=Basket"}, "", 0,
+			"ty://?Page=Home\tsearch\tq=saat\tParameter sort is not allowed on this page type.\nhttps://www.trendyol.com/sepet\tstatic\t\t\n"},
+		{[]string{"-strict", "https://www.trendyol.com/sr?q=saat&sort=price", "https://www.trendyol.com/kampanyalar"}, "", 1,
+			"\nty://?Page=Home\n"},
+		{[]string{"-format", "xml"}, "", 2, ""},
+		{[]string{"-csv", "links.csv"}, "", 2, ""},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		status := runConvert(test.args, strings.NewReader(test.stdin), &stdout, &stderr)
+		assert.Equal(test.expectedStatus, status, test.args)
+		assert.Equal(test.expectedOutput, stdout.String(), test.args)
+	}
+}
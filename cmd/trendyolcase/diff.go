@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"trendyolcase/pkg/service"
+)
+
+/*
+Runs 'trendyolcase diff [-old old-rules.yaml] [-new rules.yaml] [-catalog products.csv] [-format text|json] corpus'.
+Every link of the corpus is converted with both rule files and the changed ones are reported by page type, links with
+a stored conversion (links exports, baselines) are compared with it instead of the old rules. With '-save baseline.csv'
+the conversions of the -new rules are written as a baseline instead, to compare converter code changes against.
+It works offline, no database or .env file is needed. A missing -old or -new stands for the embedded rules.
+*/
+
+func runDiff(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.SetOutput(out)
+	oldPath := flags.String("old", "", "rules file before the change, the embedded rules by default")
+	newPath := flags.String("new", "", "rules file after the change, the embedded rules by default")
+	catalogPath := flags.String("catalog", "", "optional product catalog used by both sides")
+	format := flags.String("format", "text", "report format, text or json")
+	savePath := flags.String("save", "", "write the conversions of the corpus to this baseline file instead of a report")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Usage: trendyolcase diff [-old old-rules.yaml] [-new rules.yaml] [-catalog products.csv] [-format text|json] [-save baseline.csv] corpus")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("Format should be text or json.")
+	}
+
+	oldRules, err := ruleSetOrDefault(*oldPath)
+	if err != nil {
+		return err
+	}
+	newRules, err := ruleSetOrDefault(*newPath)
+	if err != nil {
+		return err
+	}
+	var catalog service.ProductCatalog
+	if *catalogPath != "" {
+		if catalog, err = service.NewFileProductCatalog(*catalogPath); err != nil {
+			return err
+		}
+	}
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	inputs, err := service.ReadDiffCorpus(f)
+	if err != nil {
+		return err
+	}
+
+	if *savePath != "" {
+		baseline, err := os.Create(*savePath)
+		if err != nil {
+			return err
+		}
+		if err := service.WriteDiffBaseline(baseline, newRules, catalog, inputs); err != nil {
+			baseline.Close()
+			return err
+		}
+		if err := baseline.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Baseline of %d links written to %s.\n", len(inputs), *savePath)
+		return nil
+	}
+
+	report := service.DiffRuleSets(oldRules, newRules, catalog, inputs)
+	if *format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	printDiffReport(out, report)
+	return nil
+}
+
+func ruleSetOrDefault(path string) (*service.RuleSet, error) {
+	if path == "" {
+		return service.DefaultRuleSet(), nil
+	}
+	return service.LoadRuleSet(path)
+}
+
+func printDiffReport(out io.Writer, report service.DiffReport) {
+	fmt.Fprintf(out, "Rules version %s -> %s: %d links checked, %d changed.\n", report.OldVersion, report.NewVersion, report.Checked, report.Changed)
+	for _, group := range report.Groups {
+		fmt.Fprintf(out, "\n%s (%d)\n", group.PageType, len(group.Changes))
+		for _, change := range group.Changes {
+			fmt.Fprintf(out, "  %s\n    - %s\n    + %s\n", change.Link, change.Old, change.New)
+			if change.NewPageType != change.OldPageType {
+				fmt.Fprintf(out, "    page type: %s -> %s\n", change.OldPageType, change.NewPageType)
+			}
+		}
+	}
+}
@@ -9,17 +9,33 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 	"trendyolcase/pkg/api"
 	"trendyolcase/pkg/repository/link"
 	"trendyolcase/pkg/service"
 )
 
 type App struct {
-	Router *mux.Router
-	DB     *sql.DB
+	Router     *mux.Router
+	DB         *sql.DB
+	Repository service.LinkRepository
+	Catalog    service.ProductCatalog
+	Rules      *service.ActiveRuleSet
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		os.Exit(runConvert(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		if err := runDiff(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	err := godotenv.Load(".env")
 
 	if err != nil {
@@ -27,6 +43,24 @@ func main() {
 	}
 	a := App{}
 	_ = a.initialize(os.Getenv("CONNECTION_STRING"))
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		a.migrate(os.Getenv("REPOSITORY"), os.Getenv("SQLITE_PATH"), os.Args[2:])
+		return
+	}
+	a.Repository = a.openRepository(os.Getenv("REPOSITORY"), os.Getenv("SQLITE_PATH"))
+	a.Catalog = loadProductCatalog(os.Getenv("PRODUCT_CATALOG_PATH"))
+	a.Rules = service.NewActiveRuleSet(loadRuleSet(os.Getenv("RULES_PATH")))
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		a.backfill(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "codes" {
+		a.assignCodes()
+		return
+	}
+	a.Repository = cacheRepository(a.Repository, os.Getenv("LINK_CACHE_SIZE"), os.Getenv("LINK_CACHE_TTL"))
+	a.watchRules(os.Getenv("RULES_PATH"), os.Getenv("RULES_WATCH_INTERVAL"))
+	a.purgeCacheOnSignal()
 	a.routes()
 	err = a.run(os.Getenv("SERV_PORT"))
 }
@@ -43,6 +77,112 @@ func (a *App) initialize(connectionAdrr string) error {
 	return err
 }
 
+/*
+REPOSITORY selects where links and logs are stored: 'postgres' (default, CONNECTION_STRING),
+'sqlite' (SQLITE_PATH, 'trendyolcase.db' by default) or 'memory'.
+*/
+
+func (a *App) openRepository(kind string, sqlitePath string) service.LinkRepository {
+	switch kind {
+	case "", "postgres":
+		if err := migrateOnStart(a.DB, os.Getenv("MIGRATE_ON_START")); err != nil {
+			log.Fatalf("Database schema could not be migrated: %s", err)
+		}
+		return link.NewRepository(a.DB)
+	case "sqlite":
+		repository, err := link.NewSQLiteRepository(sqlitePathOrDefault(sqlitePath))
+		if err != nil {
+			log.Fatalf("SQLite database could not be opened: %s", err)
+		}
+		return repository
+	case "memory":
+		return link.NewMemoryRepository()
+	default:
+		log.Fatalf("REPOSITORY should be postgres, sqlite or memory.")
+		return nil
+	}
+}
+
+/*
+Lookups go through an LRU cache of LINK_CACHE_SIZE entries (10000 by default, 0 disables it) which expire
+after LINK_CACHE_TTL (10m by default). Hit and miss counters are logged every five minutes.
+*/
+
+func cacheRepository(repository service.LinkRepository, size string, ttl string) service.LinkRepository {
+	cacheSize := 10000
+	if size != "" {
+		var err error
+		cacheSize, err = strconv.Atoi(size)
+		if err != nil || cacheSize < 0 {
+			log.Fatalf("LINK_CACHE_SIZE should be a number of entries, 0 disables the cache.")
+		}
+	}
+	if cacheSize == 0 {
+		return repository
+	}
+	cacheTTL := 10 * time.Minute
+	if ttl != "" {
+		var err error
+		cacheTTL, err = time.ParseDuration(ttl)
+		if err != nil || cacheTTL < 0 {
+			log.Fatalf("LINK_CACHE_TTL should be a duration like '10m'.")
+		}
+	}
+	cached := service.NewCachedLinkRepository(repository, cacheSize, cacheTTL)
+	go func() {
+		for range time.Tick(5 * time.Minute) {
+			stats := cached.Stats()
+			log.Printf("Link cache hits= %d misses= %d size= %d.", stats.Hits, stats.Misses, stats.Size)
+		}
+	}()
+	return cached
+}
+
+/*
+SIGHUP drops every cached lookup, e.g. after 'backfill -apply' rewrote stored mappings from another process.
+*/
+
+func (a *App) purgeCacheOnSignal() {
+	cached, ok := a.Repository.(*service.CachedLinkRepository)
+	if !ok {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			cached.Purge()
+			log.Printf("Link cache purged.")
+		}
+	}()
+}
+
+func sqlitePathOrDefault(path string) string {
+	if path == "" {
+		return "trendyolcase.db"
+	}
+	return path
+}
+
+func (a *App) migrate(kind string, sqlitePath string, args []string) {
+	db, dialect := a.DB, link.Postgres
+	switch kind {
+	case "", "postgres":
+	case "sqlite":
+		var err error
+		db, err = link.OpenSQLite(sqlitePathOrDefault(sqlitePath))
+		if err != nil {
+			log.Fatalf("SQLite database could not be opened: %s", err)
+		}
+		dialect = link.SQLite
+	default:
+		log.Fatalf("Migrations are only available for postgres and sqlite repositories.")
+	}
+	if err := runMigrate(db, dialect, args, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func (a *App) run(addr string) error {
 	err := http.ListenAndServe(addr, a.Router)
 	if err != nil {
@@ -53,14 +193,125 @@ func (a *App) run(addr string) error {
 }
 
 func (a *App) routes() {
-	converterAPI := InitConverterAPI(a.DB)
+	converterAPI := InitConverterAPI(a.Repository, a.Catalog, a.Rules)
 	a.Router.HandleFunc("/getDeepLink", converterAPI.GenerateDeepLink()).Methods("POST")
 	a.Router.HandleFunc("/getWebURL", converterAPI.GenerateWebURL()).Methods("POST")
+	a.Router.HandleFunc("/v1/deeplinks:batch", converterAPI.GenerateDeepLinks()).Methods("POST")
+	a.Router.HandleFunc("/v1/weburls:batch", converterAPI.GenerateWebURLs()).Methods("POST")
+	a.Router.HandleFunc("/v1/links:stream", converterAPI.StreamLinks()).Methods("POST")
+	a.Router.HandleFunc("/v1/codes", converterAPI.ShortenLink()).Methods("POST")
+	a.Router.HandleFunc("/open", converterAPI.Open()).Methods("GET")
+	a.Router.HandleFunc("/v1/deferred/claim", converterAPI.ClaimDeferredDeepLink()).Methods("POST")
+	a.Router.HandleFunc("/.well-known/apple-app-site-association", converterAPI.AppleAppSiteAssociation()).Methods("GET")
+	a.Router.HandleFunc("/.well-known/assetlinks.json", converterAPI.AssetLinks()).Methods("GET")
+	// Short codes take any first path segment, so the route comes after the others.
+	a.Router.HandleFunc("/{code:[A-Za-z0-9][A-Za-z0-9_-]*}", converterAPI.Redirect()).Methods("GET")
 }
 
-func InitConverterAPI(db *sql.DB) api.ConverterAPI {
-	converterRepository := link.NewRepository(db)
-	converterService := service.NewConverterService(converterRepository)
-	converterAPI := api.NewConverterAPI(converterService)
+func InitConverterAPI(repository service.LinkRepository, catalog service.ProductCatalog, rules *service.ActiveRuleSet) api.ConverterAPI {
+	converterAPI := api.NewConverterAPI(initConverterService(repository, catalog, rules))
+	if size := os.Getenv("BATCH_MAX_SIZE"); size != "" {
+		maxBatchSize, err := strconv.Atoi(size)
+		if err != nil || maxBatchSize <= 0 {
+			log.Fatalf("BATCH_MAX_SIZE should be a positive number of links.")
+		}
+		converterAPI.MaxBatchSize = maxBatchSize
+	}
+	if size := os.Getenv("STREAM_MAX_SIZE"); size != "" {
+		maxStreamSize, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || maxStreamSize <= 0 {
+			log.Fatalf("STREAM_MAX_SIZE should be a positive number of bytes.")
+		}
+		converterAPI.MaxStreamSize = maxStreamSize
+	}
+	if timeout := os.Getenv("OPEN_FALLBACK_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			log.Fatalf("OPEN_FALLBACK_TIMEOUT should be a positive duration like '1500ms'.")
+		}
+		converterAPI.OpenFallbackTimeout = d
+	}
+	if window := os.Getenv("DEFERRED_CLAIM_WINDOW"); window != "" {
+		d, err := time.ParseDuration(window)
+		if err != nil || d <= 0 {
+			log.Fatalf("DEFERRED_CLAIM_WINDOW should be a positive duration like '1h'.")
+		}
+		converterAPI.ConverterService.DeferredWindow = d
+	}
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		trustedProxies, err := strconv.Atoi(proxies)
+		if err != nil || trustedProxies < 0 {
+			log.Fatalf("TRUSTED_PROXIES should be the number of proxies in front of the service.")
+		}
+		converterAPI.TrustedProxies = trustedProxies
+	}
+	if converterAPI.TrustedProxies == 0 {
+		log.Printf("TRUSTED_PROXIES is 0, X-Forwarded-For is ignored. Behind a load balancer every deferred deeplink fingerprint gets its IP address.")
+	}
 	return converterAPI
 }
+
+func initConverterService(repository service.LinkRepository, catalog service.ProductCatalog, rules *service.ActiveRuleSet) service.ConverterService {
+	converterService := service.NewConverterService(repository)
+	converterService.Catalog = catalog
+	if rules != nil {
+		converterService.ActiveRules = rules
+	}
+	return converterService
+}
+
+/*
+Product catalog is optional. Without it product URLs are created with the placeholder slugs.
+*/
+
+func loadProductCatalog(path string) service.ProductCatalog {
+	if path == "" {
+		return nil
+	}
+	catalog, err := service.NewFileProductCatalog(path)
+	if err != nil {
+		log.Fatalf("Product catalog could not be loaded: %s", err)
+	}
+	return catalog
+}
+
+/*
+Rules file is optional. Without it the rules embedded from pkg/service/rules.yaml are used.
+*/
+
+func loadRuleSet(path string) *service.RuleSet {
+	if path == "" {
+		return service.DefaultRuleSet()
+	}
+	rules, err := service.LoadRuleSet(path)
+	if err != nil {
+		log.Fatalf("Conversion rules could not be loaded: %s", err)
+	}
+	log.Printf("Conversion rules version %s loaded from %s.", rules.Version, path)
+	return rules
+}
+
+/*
+Rules file is loaded again on SIGHUP and, when RULES_WATCH_INTERVAL is set (e.g. '30s'),
+whenever its modification time changes. Invalid files are rejected and the active rules are kept.
+*/
+
+func (a *App) watchRules(path string, interval string) {
+	if path == "" {
+		return
+	}
+	reloader := service.NewRuleReloader(path, a.Rules)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go reloader.WatchSignals(signals)
+
+	if interval == "" {
+		return
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil || d <= 0 {
+		log.Fatalf("RULES_WATCH_INTERVAL should be a positive duration like '30s'.")
+	}
+	go reloader.WatchFile(d, nil)
+}
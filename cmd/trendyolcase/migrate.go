@@ -0,0 +1,74 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"trendyolcase/pkg/repository/link"
+)
+
+/*
+Runs 'trendyolcase migrate up|down|status' against the database selected by REPOSITORY.
+up applies the pending migrations, down rolls back the latest one and status lists all of them.
+*/
+
+func runMigrate(db *sql.DB, dialect string, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Usage: trendyolcase migrate up|down|status")
+	}
+	migrator, err := link.NewMigrator(db, dialect)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			fmt.Fprintf(out, "Applied %04d_%s.\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(out, "Schema is up to date.")
+		}
+		return err
+	case "down":
+		m, ok, err := migrator.Down()
+		if ok {
+			fmt.Fprintf(out, "Rolled back %04d_%s.\n", m.Version, m.Name)
+		} else if err == nil {
+			fmt.Fprintln(out, "There is no migration to roll back.")
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+		return nil
+	default:
+		return fmt.Errorf("Usage: trendyolcase migrate up|down|status")
+	}
+}
+
+/*
+Postgres schema is migrated at startup only when MIGRATE_ON_START is 'true'. Migrations change a shared database,
+by default they are a deployment step run with 'trendyolcase migrate up'.
+*/
+
+func migrateOnStart(db *sql.DB, setting string) error {
+	if setting != "true" {
+		return nil
+	}
+	migrator, err := link.NewMigrator(db, link.Postgres)
+	if err != nil {
+		return err
+	}
+	_, err = migrator.Up()
+	return err
+}
@@ -2,69 +2,111 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"time"
 	"trendyolcase/pkg/model"
+	"trendyolcase/pkg/repository/link"
 	"trendyolcase/pkg/service"
 )
 
 type ConverterAPI struct {
-	ConverterService service.ConverterService
+	ConverterService    service.ConverterService
+	MaxBatchSize        int
+	MaxStreamSize       int64
+	OpenFallbackTimeout time.Duration
+	// Number of proxies in front of the service, the client IP of deferred deeplink fingerprints is taken from
+	// their X-Forwarded-For entries.
+	TrustedProxies int
 }
 
 func NewConverterAPI(c service.ConverterService) ConverterAPI {
-	return ConverterAPI{ConverterService: c}
+	return ConverterAPI{ConverterService: c, MaxBatchSize: DefaultMaxBatchSize, MaxStreamSize: DefaultMaxStreamSize, OpenFallbackTimeout: DefaultOpenFallbackTimeout}
+}
+
+/*
+DeepLinkRequest is the body of /getDeepLink, Format is the format of the returned deeplink ('scheme' by default or 'intent').
+*/
+
+type DeepLinkRequest struct {
+	model.Link
+	Format string `json:"format"`
 }
 
 /* The URL is taken from the incoming request and if there is already
 a deeplink for this URL,it is returned as a response,
-otherwise a deeplink is created for this URL. */
+otherwise a deeplink is created for this URL and saved before it is returned.
+Attribution parameters (utm_*, adjust_*, gclid) are taken out before the lookup and put back on the response.
+'"format": "intent"' returns an Android intent:// URL opening the deeplink, with the URL as browser fallback.
+The response tells whether the returned link is the fallback page and why.
+With '?explain=true' the response also explains how the current rules convert the URL.
+With '?strict=true' a malformed link of a recognized page type gets 422 and the fallback reason instead of the home page. */
 
 func (c ConverterAPI) GenerateDeepLink() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		link := model.Link{}
+		request := DeepLinkRequest{}
+		c := c.pinRules(w)
 
 		body, _ := ioutil.ReadAll(r.Body)
-		err := json.Unmarshal(body, &link)
+		err := json.Unmarshal(body, &request)
 		if err != nil {
 			message := "There is an error in the requested data. Check the data. Data should be JSON."
 			_ = c.ConverterService.InsertLog(message)
 			RespondError(w, http.StatusBadRequest, message)
 			return
 		}
-		requestLink := link.WebUrl
-
-		link.Deeplink, _ = c.ConverterService.GetDeepLinkIfWebURLExist(requestLink)
-
-		if link.Deeplink != "" {
-			logMessage := "WebURL= " + requestLink + " exists in db. Response= " + link.Deeplink + " successfully returned with data from db."
-			_ = c.ConverterService.InsertLog(logMessage)
-			RespondDeepLinkWithJSON(w, http.StatusOK, link.Deeplink)
+		if err := service.ValidateDeepLinkFormat(request.Format); err != nil {
+			_ = c.ConverterService.InsertLog(err.Error())
+			RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		link := request.Link
+		requestLink, attribution := service.ExtractAttribution(link.WebUrl)
+		result, created, err := c.ConverterService.GetOrCreateDeepLinkResult(requestLink, attribution)
+		if err == nil && queryFlag(r, "strict") && result.Malformed() {
+			err = &service.MalformedLinkError{Result: result}
+		}
+		if err != nil {
+			c.respondConversionError(w, err)
 			return
-		} else {
-			link.Deeplink, err = c.ConverterService.CreateDeepLink(requestLink)
+		}
+		link.Deeplink = result.Output
+		logMessage := "WebURL= " + requestLink + " exists in db. Response= " + link.Deeplink + " successfully returned with data from db." + attributionLog(attribution)
+		if created {
+			logMessage = "Response=" + link.Deeplink + "successfully created and returned as response. Saved to DB." + attributionLog(attribution)
+		}
+		_ = c.ConverterService.InsertLog(logMessage)
+		deepLink, err := c.ConverterService.FormatDeepLink(attribution.AppendTo(link.Deeplink), request.Format, link.WebUrl)
+		if err != nil {
+			c.respondConversionError(w, err)
+			return
+		}
+		if queryFlag(r, "explain") {
+			explanation, err := c.ConverterService.ExplainDeepLink(requestLink)
 			if err != nil {
-				message := err.Error()
-				_ = c.ConverterService.InsertLog(message)
-				RespondError(w, http.StatusBadRequest, message)
+				c.respondConversionError(w, err)
 				return
 			}
-			logMessage := "Response=" + link.Deeplink + "successfully created and returned as response. Saved to DB."
-			_ = c.ConverterService.InsertLog(logMessage)
-			RespondDeepLinkWithJSON(w, http.StatusOK, link.Deeplink)
-			c.ConverterService.Insert(requestLink, link.Deeplink)
+			RespondExplainedWithJSON(w, http.StatusOK, "deeplink", deepLink, result, explanation)
 			return
 		}
+		RespondConvertedWithJSON(w, http.StatusOK, "deeplink", deepLink, result)
 	}
 }
 
 /* The deeplink is taken from the incoming request and if there is already
 a URL for this deeplink,it is returned as a response,
-otherwise, a URL is created for this deeplink. */
+otherwise, a URL is created for this deeplink and saved before it is returned.
+Attribution parameters (utm_*, adjust_*, gclid) are taken out before the lookup and put back on the response.
+The response tells whether the returned link is the fallback page and why.
+With '?explain=true' the response also explains how the current rules convert the deeplink.
+With '?strict=true' a malformed link of a recognized page type gets 422 and the fallback reason instead of the home page. */
 
 func (c ConverterAPI) GenerateWebURL() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		link := model.Link{}
+		c := c.pinRules(w)
 		body, _ := ioutil.ReadAll(r.Body)
 		err := json.Unmarshal(body, &link)
 		if err != nil {
@@ -73,28 +115,81 @@ func (c ConverterAPI) GenerateWebURL() http.HandlerFunc {
 			RespondError(w, http.StatusBadRequest, message)
 			return
 		}
-		requestLink := link.Deeplink
-
-		// Check if there is a URL for the deeplink received from the request
-		link.WebUrl, _ = c.ConverterService.GetWebURLIfDeepLinkExist(requestLink)
-		if link.WebUrl != "" {
-			logMessage := "Deeplink= " + requestLink + " exists in db. Response= " + link.WebUrl + " successfully returned with data from db."
-			_ = c.ConverterService.InsertLog(logMessage)
-			RespondWebURLWithJSON(w, http.StatusOK, link.WebUrl)
+		requestLink, attribution := service.ExtractAttribution(link.Deeplink)
+		result, created, err := c.ConverterService.GetOrCreateWebURLResult(requestLink, attribution)
+		if err == nil && queryFlag(r, "strict") && result.Malformed() {
+			err = &service.MalformedLinkError{Result: result}
+		}
+		if err != nil {
+			c.respondConversionError(w, err)
 			return
-		} else {
-			link.WebUrl, err = c.ConverterService.CreateWebURL(requestLink)
+		}
+		link.WebUrl = result.Output
+		logMessage := "Deeplink= " + requestLink + " exists in db. Response= " + link.WebUrl + " successfully returned with data from db." + attributionLog(attribution)
+		if created {
+			logMessage = "Response= " + link.WebUrl + " successfully created and returned as response. Saved to DB." + attributionLog(attribution)
+		}
+		_ = c.ConverterService.InsertLog(logMessage)
+		if queryFlag(r, "explain") {
+			explanation, err := c.ConverterService.ExplainWebURL(requestLink)
 			if err != nil {
-				message := err.Error()
-				_ = c.ConverterService.InsertLog(message)
-				RespondError(w, http.StatusBadRequest, message)
+				c.respondConversionError(w, err)
 				return
 			}
-			logMessage := "Response= " + link.WebUrl + " successfully created and returned as response. Saved to DB."
-			_ = c.ConverterService.InsertLog(logMessage)
-			RespondWebURLWithJSON(w, http.StatusOK, link.WebUrl)
-			c.ConverterService.Insert(link.WebUrl, requestLink)
+			RespondExplainedWithJSON(w, http.StatusOK, "weburl", attribution.AppendTo(link.WebUrl), result, explanation)
 			return
 		}
+		RespondConvertedWithJSON(w, http.StatusOK, "weburl", attribution.AppendTo(link.WebUrl), result)
+	}
+}
+
+/*
+API converting with the rules active when the request came in, the X-Rules-Version header tells their version.
+A reload during the request doesn't change the rules of its response.
+*/
+
+func (c ConverterAPI) pinRules(w http.ResponseWriter) ConverterAPI {
+	c.ConverterService = c.ConverterService.Snapshot()
+	SetRulesVersionHeader(w, c.ConverterService.RulesVersion())
+	return c
+}
+
+/*
+Invalid links are bad requests and malformed links of strict requests are unprocessable.
+When the converted link could not be saved the request fails, so a link is only returned once it is stored.
+*/
+
+func (c ConverterAPI) respondConversionError(w http.ResponseWriter, err error) {
+	message := err.Error()
+	_ = c.ConverterService.InsertLog(message)
+	var malformed *service.MalformedLinkError
+	if errors.As(err, &malformed) {
+		RespondMalformedLink(w, malformed.Result)
+		return
 	}
+	if errors.Is(err, link.ErrWrite) {
+		RespondError(w, http.StatusInternalServerError, link.ErrWrite.Error())
+		return
+	}
+	RespondError(w, http.StatusBadRequest, message)
+}
+
+/*
+Every request's attribution goes to the logs, the links table only keeps the attribution of the request that created the mapping.
+*/
+
+func attributionLog(attribution service.Attribution) string {
+	if len(attribution) == 0 {
+		return ""
+	}
+	return " Attribution= " + attribution.Encode()
+}
+
+/*
+Options of the single link endpoints, e.g. '?explain=true'. The explanation comes from the current rules,
+so it can differ from a response returned from the db.
+*/
+
+func queryFlag(r *http.Request, name string) bool {
+	return r.URL.Query().Get(name) == "true"
 }
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"trendyolcase/pkg/model"
+	"trendyolcase/pkg/repository/link"
+	"trendyolcase/pkg/service"
+)
+
+func TestConvertResponseFallback(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterAPI(service.NewConverterService(link.NewMemoryRepository()))
+
+	tests := []struct {
+		handler            http.HandlerFunc
+		target             string
+		body               string
+		expectedCode       int
+		expectedFallback   bool
+		expectedReasonCode string
+	}{
+		{c.GenerateDeepLink(), "/getDeepLink", `{"weburl": "https://www.trendyol.com/sr?q=saat"}`, http.StatusOK, false, ""},
+		{c.GenerateDeepLink(), "/getDeepLink", `{"weburl": "https://www.trendyol.com/sr?q="}`, http.StatusOK, true, "missing_id"},
+		{c.GenerateDeepLink(), "/getDeepLink?strict=true", `{"weburl": "https://www.trendyol.com/sr?q="}`, http.StatusUnprocessableEntity, false, ""},
+		{c.GenerateDeepLink(), "/getDeepLink?strict=true", `{"weburl": "https://www.trendyol.com/kampanyalar"}`, http.StatusOK, true, "unknown_page"},
+		{c.GenerateWebURL(), "/getWebURL", `{"deeplink": "ty://?Page=Campaigns"}`, http.StatusOK, true, "unknown_page"},
+		{c.GenerateWebURL(), "/getWebURL?explain=true", `{"deeplink": "ty://?Page=Product&ContentId="}`, http.StatusOK, true, "missing_id"},
+		// The fallback fields describe the link stored by the first request.
+		{c.GenerateDeepLink(), "/getDeepLink?explain=true", `{"weburl": "https://www.trendyol.com/sr?q=saat"}`, http.StatusOK, false, ""},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		test.handler(w, httptest.NewRequest(http.MethodPost, test.target, strings.NewReader(test.body)))
+		assert.Equal(test.expectedCode, w.Code, test.body)
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var response struct {
+			Fallback   bool   `json:"fallback"`
+			ReasonCode string `json:"reasonCode"`
+			Reason     string `json:"reason"`
+		}
+		assert.Nil(json.Unmarshal(w.Body.Bytes(), &response), test.body)
+		assert.Equal(test.expectedFallback, response.Fallback, test.body)
+		assert.Equal(test.expectedReasonCode, response.ReasonCode, test.body)
+		assert.Equal(test.expectedFallback, response.Reason != "", test.body)
+	}
+}
+
+func TestBatchFallback(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterAPI(service.NewConverterService(link.NewMemoryRepository()))
+
+	body := `[{"weburl": "https://www.trendyol.com/sr?q=saat"}, {"weburl": "https://www.trendyol.com/kampanyalar"}, {"weburl": "https://www.trendyol.com/sr?q="}]`
+	w := httptest.NewRecorder()
+	c.GenerateDeepLinks()(w, httptest.NewRequest(http.MethodPost, "/v1/deeplinks:batch", strings.NewReader(body)))
+	assert.Equal(http.StatusOK, w.Code)
+	var response struct {
+		Results []BatchItem `json:"results"`
+	}
+	assert.Nil(json.Unmarshal(w.Body.Bytes(), &response))
+	items := response.Results
+	if !assert.Equal(3, len(items)) {
+		return
+	}
+	assert.False(items[0].Fallback)
+	assert.Equal(service.FallbackReason(""), items[0].ReasonCode)
+	assert.True(items[1].Fallback)
+	assert.Equal(service.ReasonUnknownPage, items[1].ReasonCode)
+	assert.True(items[2].Fallback)
+	assert.Equal(service.ReasonMissingID, items[2].ReasonCode)
+	assert.NotEmpty(items[2].Reason)
+
+	_, streamed := streamLinks(t, c, `{"deeplink": "ty://?Page=Campaigns"}
+`)
+	if assert.Equal(1, len(streamed)) {
+		assert.True(streamed[0].Fallback)
+		assert.Equal(service.ReasonUnknownPage, streamed[0].ReasonCode)
+	}
+}
+
+func TestBatchBodySize(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterAPI(service.NewConverterService(link.NewMemoryRepository()))
+	c.MaxBatchSize = 1
+
+	// The body is cut off at 8 KiB per link of the batch size, before it is parsed.
+	body := `[{"weburl": "https://www.trendyol.com/sr?q=` + strings.Repeat("a", batchMaxLinkSize) + `"}]`
+	w := httptest.NewRecorder()
+	c.GenerateDeepLinks()(w, httptest.NewRequest(http.MethodPost, "/v1/deeplinks:batch", strings.NewReader(body)))
+	assert.Equal(http.StatusRequestEntityTooLarge, w.Code)
+
+	body = `[{"weburl": "https://www.trendyol.com/sr?q=saat"}]`
+	w = httptest.NewRecorder()
+	c.GenerateDeepLinks()(w, httptest.NewRequest(http.MethodPost, "/v1/deeplinks:batch", strings.NewReader(body)))
+	assert.Equal(http.StatusOK, w.Code)
+}
+
+func TestOpenForeignCode(t *testing.T) {
+	assert := assert.New(t)
+	repository := link.NewMemoryRepository()
+	c := NewConverterAPI(service.NewConverterService(repository))
+	_, err := repository.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=saat", Deeplink: "javascript:alert(1)"}})
+	assert.Nil(err)
+	_, err = repository.AssignCode("https://www.trendyol.com/sr?q=saat", "saat")
+	assert.Nil(err)
+
+	r := httptest.NewRequest(http.MethodGet, "/open?code=saat", nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X)")
+	w := httptest.NewRecorder()
+	c.Open()(w, r)
+	assert.Equal(http.StatusBadRequest, w.Code)
+	assert.NotContains(w.Body.String(), "javascript:")
+}
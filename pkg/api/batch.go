@@ -0,0 +1,158 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"trendyolcase/pkg/model"
+	"trendyolcase/pkg/repository/link"
+	"trendyolcase/pkg/service"
+)
+
+const (
+	DefaultMaxBatchSize = 1000
+	// Bytes a batch body may have per link of MaxBatchSize, larger bodies get 413 before they are parsed.
+	batchMaxLinkSize = 8 * 1024
+)
+
+/*
+BatchItem is one result of a batch, the input with its conversion or its error. Like the single conversions, it tells
+whether the conversion is the fallback page and why.
+*/
+
+type BatchItem struct {
+	WebUrl     string                 `json:"weburl,omitempty"`
+	Deeplink   string                 `json:"deeplink,omitempty"`
+	Created    bool                   `json:"created,omitempty"`
+	Fallback   bool                   `json:"fallback,omitempty"`
+	ReasonCode service.FallbackReason `json:"reasonCode,omitempty"`
+	Reason     string                 `json:"reason,omitempty"`
+	Error      *ErrorResponse         `json:"error,omitempty"`
+}
+
+/* An array of URLs, e.g. '[{"weburl": "..."}, {"weburl": "..."}]', is converted to deeplinks.
+The results are returned in the order of the inputs, an input which can't be converted gets its own error. */
+
+func (c ConverterAPI) GenerateDeepLinks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := c.pinRules(w)
+		links, ok := c.readBatch(w, r, "weburl")
+		if !ok {
+			return
+		}
+		webURLs := make([]string, len(links))
+		for i, l := range links {
+			webURLs[i] = l.WebUrl
+		}
+		results, err := c.ConverterService.BatchDeepLinks(webURLs)
+		if err != nil {
+			_ = c.ConverterService.InsertLog(err.Error())
+			RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		items := make([]BatchItem, len(results))
+		for i, result := range results {
+			items[i] = batchItem(result, true)
+		}
+		c.logBatch("web URLs", results)
+		RespondBatchWithJSON(w, http.StatusOK, items)
+	}
+}
+
+/* An array of deeplinks, e.g. '[{"deeplink": "..."}, {"deeplink": "..."}]', is converted to URLs.
+The results are returned in the order of the inputs, an input which can't be converted gets its own error. */
+
+func (c ConverterAPI) GenerateWebURLs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := c.pinRules(w)
+		links, ok := c.readBatch(w, r, "deeplink")
+		if !ok {
+			return
+		}
+		deepLinks := make([]string, len(links))
+		for i, l := range links {
+			deepLinks[i] = l.Deeplink
+		}
+		results, err := c.ConverterService.BatchWebURLs(deepLinks)
+		if err != nil {
+			_ = c.ConverterService.InsertLog(err.Error())
+			RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		items := make([]BatchItem, len(results))
+		for i, result := range results {
+			items[i] = batchItem(result, false)
+		}
+		c.logBatch("deeplinks", results)
+		RespondBatchWithJSON(w, http.StatusOK, items)
+	}
+}
+
+func (c ConverterAPI) readBatch(w http.ResponseWriter, r *http.Request, tag string) ([]model.Link, bool) {
+	var links []model.Link
+	body := newLimitedBody(w, r.Body, int64(c.MaxBatchSize)*batchMaxLinkSize)
+	data, _ := ioutil.ReadAll(body)
+	if body.tooLarge() {
+		message := "Body is larger than " + strconv.FormatInt(body.limit, 10) + " bytes, at most " + strconv.Itoa(c.MaxBatchSize) + " links can be converted at once."
+		_ = c.ConverterService.InsertLog(message)
+		RespondError(w, http.StatusRequestEntityTooLarge, message)
+		return nil, false
+	}
+	if err := json.Unmarshal(data, &links); err != nil || len(links) == 0 {
+		message := "There is an error in the requested data. Check the data. Data should be a JSON array of objects with the '" + tag + "' tag."
+		_ = c.ConverterService.InsertLog(message)
+		RespondError(w, http.StatusBadRequest, message)
+		return nil, false
+	}
+	if len(links) > c.MaxBatchSize {
+		message := fmt.Sprintf("Batch has %d links, at most %d links can be converted at once.", len(links), c.MaxBatchSize)
+		_ = c.ConverterService.InsertLog(message)
+		RespondError(w, http.StatusRequestEntityTooLarge, message)
+		return nil, false
+	}
+	return links, true
+}
+
+/*
+The item of a batch result, toDeepLink tells whether the input is a webURL converted to a deeplink.
+*/
+
+func batchItem(result service.BatchResult, toDeepLink bool) BatchItem {
+	item := BatchItem{Created: result.Created, Fallback: result.Fallback, ReasonCode: result.ReasonCode, Reason: result.Reason, Error: batchItemError(result.Err)}
+	if toDeepLink {
+		item.WebUrl, item.Deeplink = result.Input, result.Output
+	} else {
+		item.Deeplink, item.WebUrl = result.Input, result.Output
+	}
+	return item
+}
+
+func batchItemError(err error) *ErrorResponse {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, link.ErrWrite) {
+		return &ErrorResponse{Code: http.StatusInternalServerError, Status: "Error", Message: link.ErrWrite.Error()}
+	}
+	return &ErrorResponse{Code: http.StatusBadRequest, Status: "Error", Message: err.Error()}
+}
+
+/*
+A batch is logged once, with its size and how many links were created or failed.
+*/
+
+func (c ConverterAPI) logBatch(kind string, results []service.BatchResult) {
+	created, failed := 0, 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		} else if result.Created {
+			created++
+		}
+	}
+	_ = c.ConverterService.InsertLog("Batch of " + strconv.Itoa(len(results)) + " " + kind + " converted. Created= " +
+		strconv.Itoa(created) + " Failed= " + strconv.Itoa(failed) + ".")
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"trendyolcase/pkg/repository/link"
+	"trendyolcase/pkg/service"
+)
+
+/*
+ClaimRequest is sent by the app on its first start. Token is the click_token of the install referrer when the
+Android app was installed from the /open page's store link, Platform ('ios' or 'android') is needed for the
+fingerprint otherwise. iOS apps always claim by fingerprint. An app which sends both is claimed by fingerprint when
+its token is not found.
+*/
+
+type ClaimRequest struct {
+	Token    string `json:"token"`
+	Platform string `json:"platform"`
+}
+
+/* The freshly installed app claims the deeplink of the /open click that sent it to the store, within
+the DeferredWindow of the service. The click is found by its token, or by the IP address and platform of
+the phone, when the token is not found. A click is claimed once, 404 when there is nothing to claim. */
+
+func (c ConverterAPI) ClaimDeferredDeepLink() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request := ClaimRequest{}
+		body, _ := ioutil.ReadAll(r.Body)
+		err := json.Unmarshal(body, &request)
+		platform := service.Platform(request.Platform)
+		if err != nil || (request.Token == "" && platform != service.PlatformIOS && platform != service.PlatformAndroid) {
+			message := "There is an error in the requested data. Check the data. Data should be JSON with the 'token' tag or the 'platform' tag ('ios' or 'android')."
+			_ = c.ConverterService.InsertLog(message)
+			RespondError(w, http.StatusBadRequest, message)
+			return
+		}
+
+		fingerprint := ""
+		if platform != "" {
+			fingerprint = service.ClickFingerprint(c.clientIP(r), platform)
+		}
+		deepLink, err := c.ConverterService.ClaimDeferredDeepLink(request.Token, fingerprint)
+		if errors.Is(err, link.ErrNotFound) {
+			RespondError(w, http.StatusNotFound, "There is no deeplink to claim.")
+			return
+		}
+		if err != nil {
+			_ = c.ConverterService.InsertLog(err.Error())
+			RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		_ = c.ConverterService.InsertLog("Deferred Deeplink= " + deepLink + " claimed.")
+		RespondDeepLinkWithJSON(w, http.StatusOK, deepLink)
+	}
+}
+
+/*
+Behind TrustedProxies proxies which append the address they got the request from to X-Forwarded-For, the client is
+the address the outermost of them saw, the TrustedProxies-th from the right. Addresses left of it come from the client
+and can be anything. Without trusted proxies the header is ignored.
+*/
+
+func (c ConverterAPI) clientIP(r *http.Request) string {
+	var hops []string
+	for _, forwarded := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(forwarded, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	if c.TrustedProxies > 0 && len(hops) > 0 {
+		if c.TrustedProxies >= len(hops) {
+			return hops[0]
+		}
+		return hops[len(hops)-c.TrustedProxies]
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"github.com/stretchr/testify/assert"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"trendyolcase/pkg/repository/link"
+	"trendyolcase/pkg/service"
+)
+
+func TestClientIP(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		trustedProxies int
+		forwardedFor   []string
+		expected       string
+	}{
+		{0, nil, "10.0.0.1"},
+		{0, []string{"203.0.113.7"}, "10.0.0.1"},
+		{1, nil, "10.0.0.1"},
+		{1, []string{"203.0.113.7"}, "203.0.113.7"},
+		// The client sent its own header, the proxy appended the address it saw.
+		{1, []string{"198.51.100.1, 203.0.113.7"}, "203.0.113.7"},
+		{1, []string{"198.51.100.1", "203.0.113.7"}, "203.0.113.7"},
+		{2, []string{"198.51.100.1, 203.0.113.7, 10.0.0.2"}, "203.0.113.7"},
+		{2, []string{"203.0.113.7"}, "203.0.113.7"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/v1/deferred/claim", nil)
+		r.RemoteAddr = "10.0.0.1:43210"
+		for _, forwarded := range test.forwardedFor {
+			r.Header.Add("X-Forwarded-For", forwarded)
+		}
+		c := ConverterAPI{TrustedProxies: test.trustedProxies}
+		assert.Equal(test.expected, c.clientIP(r), test.forwardedFor)
+	}
+}
+
+func TestOpenStoreReferrer(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterAPI(service.NewConverterService(link.NewMemoryRepository()))
+
+	r := httptest.NewRequest(http.MethodGet, "/open?url="+url.QueryEscape("https://www.trendyol.com/sr?q=saat"), nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0 (Linux; Android 13; Pixel 7) Chrome/116.0 Mobile Safari/537.36")
+	w := httptest.NewRecorder()
+	c.Open()(w, r)
+	assert.Equal(http.StatusOK, w.Code)
+	match := regexp.MustCompile(`href="(https://play\.google\.com/[^"]+)"`).FindStringSubmatch(w.Body.String())
+	if !assert.NotNil(match, w.Body.String()) {
+		return
+	}
+	storeURL, err := url.Parse(html.UnescapeString(match[1]))
+	assert.Nil(err)
+	assert.Equal("trendyol.com", storeURL.Query().Get("id"))
+	referrer, err := url.ParseQuery(storeURL.Query().Get("referrer"))
+	assert.Nil(err)
+
+	// The app installed from the store link claims the click with the referrer's token.
+	body := `{"token": "` + referrer.Get(service.ClickTokenReferrerParam) + `"}`
+	w = httptest.NewRecorder()
+	c.ClaimDeferredDeepLink()(w, httptest.NewRequest(http.MethodPost, "/v1/deferred/claim", strings.NewReader(body)))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), "Page=Search")
+	w = httptest.NewRecorder()
+	c.ClaimDeferredDeepLink()(w, httptest.NewRequest(http.MethodPost, "/v1/deferred/claim", strings.NewReader(body)))
+	assert.Equal(http.StatusNotFound, w.Code)
+
+	// iOS has no store referrer.
+	r.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X)")
+	w = httptest.NewRecorder()
+	c.Open()(w, r)
+	assert.NotContains(w.Body.String(), "play.google.com")
+}
+
+func TestClaimUnknownToken(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterAPI(service.NewConverterService(link.NewMemoryRepository()))
+	fingerprint := service.ClickFingerprint("10.0.0.1", service.PlatformAndroid)
+	_, err := c.ConverterService.RecordClick("ty://?Page=Search&Query=saat", fingerprint)
+	assert.Nil(err)
+
+	tests := []struct {
+		body     string
+		expected int
+	}{
+		{`{"token": "unknown"}`, http.StatusNotFound},
+		// The token is not found, the click is claimed by the fingerprint.
+		{`{"token": "unknown", "platform": "android"}`, http.StatusOK},
+		{`{"token": "unknown", "platform": "android"}`, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/v1/deferred/claim", strings.NewReader(test.body))
+		r.RemoteAddr = "10.0.0.1:43210"
+		w := httptest.NewRecorder()
+		c.ClaimDeferredDeepLink()(w, r)
+		assert.Equal(test.expected, w.Code, test.body)
+	}
+}
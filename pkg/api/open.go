@@ -0,0 +1,117 @@
+package api
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"time"
+	"trendyolcase/pkg/repository/link"
+	"trendyolcase/pkg/service"
+)
+
+const DefaultOpenFallbackTimeout = 1500 * time.Millisecond
+
+/*
+Page shown to phones. It opens the deeplink and, when the app didn't take over (the page is still visible)
+before the timeout, replaces itself with the website.
+*/
+
+var openPage = template.Must(template.New("open").Parse(`<!DOCTYPE html>
+<html lang="tr">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Trendyol</title>
+</head>
+<body>
+<p><a href="{{.WebURL}}">Continue to the website</a></p>
+{{if .StoreURL}}<p><a href="{{.StoreURL}}">Get the app</a></p>
+{{end}}<script>
+(function () {
+	var fallback = {{.WebURL}};
+	var timer = setTimeout(function () {
+		if (!document.hidden) {
+			window.location.replace(fallback);
+		}
+	}, {{.TimeoutMillis}});
+	document.addEventListener("visibilitychange", function () {
+		if (document.hidden) {
+			clearTimeout(timer);
+		}
+	});
+	window.location.href = {{.DeepLink}};
+})();
+</script>
+</body>
+</html>
+`))
+
+type openPageData struct {
+	DeepLink      string
+	WebURL        string
+	TimeoutMillis int64
+	StoreURL      string
+}
+
+/* A shared link, '/open?url=<web URL>' or '/open?code=<short code>', opens the app on phones and the website
+everywhere else. iOS and Android get a page which tries the deeplink and falls back to the website after
+OpenFallbackTimeout, desktops and crawlers (link previews, search engines) are redirected to the website.
+Android gets the intent:// URL of the deeplink when the rules have the android package, Chrome doesn't open custom schemes
+reliably. Links the app has no page for always go to the website. The click is recorded for phones, so that the app can claim
+the deeplink if it has to be installed first (see ClaimDeferredDeepLink). Android's page links the Play Store with the click
+token in the install referrer.
+URLs off the site and codes of links off the site or the app get 400. */
+
+func (c ConverterAPI) Open() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := c.pinRules(w)
+		var target service.OpenTarget
+		var err error
+		if code := r.URL.Query().Get("code"); code != "" {
+			target, err = c.ConverterService.OpenCode(code)
+		} else {
+			target, err = c.ConverterService.OpenWebURL(r.URL.Query().Get("url"))
+		}
+		if errors.Is(err, link.ErrNotFound) {
+			RespondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			_ = c.ConverterService.InsertLog(err.Error())
+			RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		platform := service.DetectPlatform(r.UserAgent())
+		w.Header().Set("Vary", "User-Agent")
+		w.Header().Set("Cache-Control", "no-store")
+		if target.DeepLink == "" || (platform != service.PlatformIOS && platform != service.PlatformAndroid) {
+			_ = c.ConverterService.InsertLog("Open= " + target.WebURL + " on " + string(platform) + ", redirected to the website.")
+			http.Redirect(w, r, target.WebURL, http.StatusFound)
+			return
+		}
+		storeURL := ""
+		clickToken, err := c.ConverterService.RecordClick(target.DeepLink, service.ClickFingerprint(c.clientIP(r), platform))
+		if err != nil {
+			// The app still opens if it is installed, only the deferred deeplink is lost.
+			_ = c.ConverterService.InsertLog("Click of Deeplink= " + target.DeepLink + " could not be recorded: " + err.Error())
+		} else if platform == service.PlatformAndroid {
+			storeURL = c.ConverterService.PlayStoreURL(clickToken)
+		}
+		if platform == service.PlatformAndroid {
+			if intent, err := c.ConverterService.FormatDeepLink(target.DeepLink, service.DeepLinkFormatIntent, target.WebURL); err == nil {
+				target.DeepLink = intent
+			}
+		}
+		_ = c.ConverterService.InsertLog("Open= " + target.WebURL + " on " + string(platform) + ", trying Deeplink= " + target.DeepLink + ".")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = openPage.Execute(w, openPageData{
+			DeepLink:      target.DeepLink,
+			WebURL:        target.WebURL,
+			TimeoutMillis: c.OpenFallbackTimeout.Milliseconds(),
+			StoreURL:      storeURL,
+		})
+	}
+}
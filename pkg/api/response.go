@@ -3,12 +3,22 @@ package api
 import (
 	"github.com/bitly/go-simplejson"
 	"net/http"
+	"trendyolcase/pkg/service"
 )
 
 type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Status  string `json:"status"`
-	Message string `json:"message"`
+	Code       int    `json:"code"`
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	ReasonCode string `json:"reasonCode,omitempty"`
+}
+
+/*
+Version of the conversion rules that produced the response.
+*/
+
+func SetRulesVersionHeader(w http.ResponseWriter, version string) {
+	w.Header().Set("X-Rules-Version", version)
 }
 
 func RespondDeepLinkWithJSON(w http.ResponseWriter, code int, converterResponse string) {
@@ -39,4 +49,78 @@ func RespondWebURLWithJSON(w http.ResponseWriter, code int, converterResponse st
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 	w.Write(payload)
-}
\ No newline at end of file
+}
+func RespondBatchWithJSON(w http.ResponseWriter, code int, items []BatchItem) {
+	json := simplejson.New()
+	json.Set("results", items)
+	payload, _ := json.MarshalJSON()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(payload)
+}
+
+/*
+Converted link under the tag of its kind ('deeplink' or 'weburl') with the fallback flag and,
+when the link fell back, the reason code and the reason.
+*/
+
+func RespondConvertedWithJSON(w http.ResponseWriter, code int, tag string, converterResponse string, result service.ConversionResult) {
+	payload, _ := convertedJSON(tag, converterResponse, result).MarshalJSON()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(payload)
+}
+
+/*
+Same as RespondConvertedWithJSON with the explanation of how the current rules convert the link.
+*/
+
+func RespondExplainedWithJSON(w http.ResponseWriter, code int, tag string, converterResponse string, result service.ConversionResult, explanation service.Explanation) {
+	json := convertedJSON(tag, converterResponse, result)
+	json.Set("explain", explanation)
+	payload, _ := json.MarshalJSON()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(payload)
+}
+
+/*
+422 for a link its page type recognized but couldn't convert, with the fallback reason code.
+*/
+
+func RespondMalformedLink(w http.ResponseWriter, result service.ConversionResult) {
+	e := ErrorResponse{
+		Code:       http.StatusUnprocessableEntity,
+		Status:     "Error",
+		Message:    result.Reason,
+		ReasonCode: string(result.ReasonCode),
+	}
+	json := simplejson.New()
+	json.Set("error", e)
+	payload, _ := json.MarshalJSON()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	w.Write(payload)
+}
+
+func RespondShortLinkWithJSON(w http.ResponseWriter, code int, short service.ShortLink) {
+	json := simplejson.New()
+	json.Set("code", short.Code)
+	json.Set("weburl", short.WebURL)
+	json.Set("deeplink", short.DeepLink)
+	payload, _ := json.MarshalJSON()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(payload)
+}
+
+func convertedJSON(tag string, converterResponse string, result service.ConversionResult) *simplejson.Json {
+	json := simplejson.New()
+	json.Set(tag, converterResponse)
+	json.Set("fallback", result.Fallback)
+	if result.Fallback {
+		json.Set("reasonCode", result.ReasonCode)
+		json.Set("reason", result.Reason)
+	}
+	return json
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"io/ioutil"
+	"net/http"
+	"trendyolcase/pkg/model"
+	"trendyolcase/pkg/repository/link"
+	"trendyolcase/pkg/service"
+)
+
+/*
+ShortenRequest is a web URL or a deeplink with an optional vanity code.
+*/
+
+type ShortenRequest struct {
+	model.Link
+	Code string `json:"code"`
+}
+
+/* The web URL or deeplink of the request, e.g. '{"weburl": "...", "code": "yaz-indirimi"}', is stored like
+/getDeepLink and /getWebURL do and returned with its short code. Without 'code' the stored code is returned or one
+is generated. A vanity code used by another link, or asked for a link which already has a code, is a conflict. */
+
+func (c ConverterAPI) ShortenLink() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := c.pinRules(w)
+		request := ShortenRequest{}
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &request); err != nil || (request.WebUrl == "") == (request.Deeplink == "") {
+			message := "There is an error in the requested data. Check the data. Data should be JSON with the 'weburl' or 'deeplink' tag."
+			_ = c.ConverterService.InsertLog(message)
+			RespondError(w, http.StatusBadRequest, message)
+			return
+		}
+
+		var short service.ShortLink
+		var err error
+		if request.WebUrl != "" {
+			requestLink, attribution := service.ExtractAttribution(request.WebUrl)
+			short, err = c.ConverterService.ShortenWebURL(requestLink, attribution, request.Code)
+		} else {
+			requestLink, attribution := service.ExtractAttribution(request.Deeplink)
+			short, err = c.ConverterService.ShortenDeepLink(requestLink, attribution, request.Code)
+		}
+		if errors.Is(err, link.ErrCodeTaken) || errors.Is(err, service.ErrHasCode) {
+			_ = c.ConverterService.InsertLog(err.Error())
+			RespondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			c.respondConversionError(w, err)
+			return
+		}
+		_ = c.ConverterService.InsertLog("Code= " + short.Code + " returned for WebURL= " + short.WebURL + ".")
+		RespondShortLinkWithJSON(w, http.StatusOK, short)
+	}
+}
+
+/* The code's web URL, or its deeplink with '?to=deeplink', is the target of a 302 redirect.
+Attribution parameters of the request, e.g. '/aB3dE9x?utm_source=sms', are put on the target.
+Codes of links off the site or the app get 400, they are never redirected to. */
+
+func (c ConverterAPI) Redirect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := mux.Vars(r)["code"]
+		stored, err := c.ConverterService.ResolveCode(code)
+		if errors.Is(err, link.ErrNotFound) {
+			RespondError(w, http.StatusNotFound, "Code "+code+" is not found.")
+			return
+		}
+		if errors.Is(err, service.ErrForeignLink) {
+			_ = c.ConverterService.InsertLog("Code= " + code + " is not redirected. " + err.Error())
+			RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			_ = c.ConverterService.InsertLog(err.Error())
+			RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		target := stored.WebUrl
+		if r.URL.Query().Get("to") == "deeplink" {
+			target = stored.Deeplink
+		}
+		_, attribution := service.ExtractAttribution(r.URL.String())
+		http.Redirect(w, r, attribution.AppendTo(target), http.StatusFound)
+	}
+}
@@ -0,0 +1,262 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"trendyolcase/pkg/model"
+	"trendyolcase/pkg/service"
+)
+
+const (
+	streamChunkSize     = 500
+	streamMaxRecordSize = 1024 * 1024
+	// Larger stream bodies are cut off with a 413 error line.
+	DefaultMaxStreamSize = 256 * 1024 * 1024
+)
+
+/*
+StreamItem is one line of a streamed response, the result of the input line with the same number.
+*/
+
+type StreamItem struct {
+	Line int `json:"line"`
+	BatchItem
+}
+
+type streamRecord struct {
+	line int
+	link model.Link
+	err  *ErrorResponse
+}
+
+/* Newline-delimited JSON records, '{"weburl": "..."}' or '{"deeplink": "..."}', are read from the body, converted
+in chunks with one repository lookup per chunk and direction, and written back as newline-delimited JSON in the
+order of the input lines. Over HTTP/2 every chunk is written before the next one is read. HTTP/1.x handlers can't
+read the request once they wrote the response, so the results are spooled to a temporary file until the body is
+read. Either way memory use doesn't depend on the size of the body. Bodies larger than MaxStreamSize end with
+a 413 error line. */
+
+func (c ConverterAPI) StreamLinks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := c.pinRules(w)
+		maxSize := c.MaxStreamSize
+		if maxSize <= 0 {
+			maxSize = DefaultMaxStreamSize
+		}
+		body := newLimitedBody(w, r.Body, maxSize)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		stream := ndjsonStream{api: c, flush: func() {}}
+
+		if r.ProtoMajor >= 2 {
+			w.WriteHeader(http.StatusOK)
+			stream.encoder = json.NewEncoder(w)
+			if flusher, ok := w.(http.Flusher); ok {
+				stream.flush = flusher.Flush
+			}
+			stream.read(body)
+			return
+		}
+
+		spool, err := ioutil.TempFile("", "links-stream-*.ndjson")
+		if err != nil {
+			_ = c.ConverterService.InsertLog("Stream results could not be spooled. " + err.Error())
+			RespondError(w, http.StatusInternalServerError, "Stream results could not be spooled.")
+			return
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+		spooled := bufio.NewWriter(spool)
+		stream.encoder = json.NewEncoder(spooled)
+		stream.read(body)
+		err = spooled.Flush()
+		if err == nil {
+			_, err = spool.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			_ = c.ConverterService.InsertLog("Stream results could not be spooled. " + err.Error())
+			RespondError(w, http.StatusInternalServerError, "Stream results could not be spooled.")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.Copy(w, spool)
+	}
+}
+
+/*
+Reads the records of the body chunk by chunk and writes their results, ending with an error line when the body
+can't be read.
+*/
+
+func (s *ndjsonStream) read(body *limitedBody) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), streamMaxRecordSize)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && body.err != nil && bytes.IndexByte(data, '\n') == -1 {
+			// The last line was cut off by the read error, it isn't converted.
+			return len(data), nil, nil
+		}
+		return bufio.ScanLines(data, atEOF)
+	})
+	chunk := make([]streamRecord, 0, streamChunkSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		chunk = append(chunk, parseStreamRecord(line, scanner.Bytes()))
+		if len(chunk) == streamChunkSize {
+			if !s.convert(chunk) {
+				return
+			}
+			chunk = chunk[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		code, message := http.StatusBadRequest, "Request body could not be read. "+err.Error()
+		switch {
+		case body.tooLarge():
+			code, message = http.StatusRequestEntityTooLarge, "Body is larger than "+strconv.FormatInt(body.limit, 10)+" bytes."
+		case errors.Is(err, bufio.ErrTooLong):
+			message = "Line is longer than " + strconv.Itoa(streamMaxRecordSize) + " bytes."
+		}
+		if s.convert(chunk) {
+			s.fail(line+1, code, message)
+		}
+		return
+	}
+	if s.convert(chunk) {
+		s.log()
+	}
+}
+
+/*
+limitedBody reads a request body through http.MaxBytesReader, counting the bytes read and keeping the read error,
+io.EOF aside. An error once limit bytes are read means the body is larger, the error of http.MaxBytesReader can't
+be told from others before Go 1.19.
+*/
+
+type limitedBody struct {
+	r     io.Reader
+	limit int64
+	n     int64
+	err   error
+}
+
+func newLimitedBody(w http.ResponseWriter, body io.ReadCloser, limit int64) *limitedBody {
+	return &limitedBody{r: http.MaxBytesReader(w, body, limit), limit: limit}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *limitedBody) tooLarge() bool {
+	return b.err != nil && b.n >= b.limit
+}
+
+func parseStreamRecord(line int, b []byte) streamRecord {
+	record := streamRecord{line: line}
+	if err := json.Unmarshal(b, &record.link); err != nil {
+		record.err = &ErrorResponse{Code: http.StatusBadRequest, Status: "Error", Message: "Line should be a JSON object with the 'weburl' or 'deeplink' tag."}
+	} else if record.link.WebUrl == "" && record.link.Deeplink == "" {
+		record.err = &ErrorResponse{Code: http.StatusBadRequest, Status: "Error", Message: "Line should have the 'weburl' or 'deeplink' tag."}
+	}
+	return record
+}
+
+type ndjsonStream struct {
+	api     ConverterAPI
+	encoder *json.Encoder
+	flush   func()
+
+	converted int
+	created   int
+	failed    int
+}
+
+/*
+Converts a chunk and writes its results. Returns false when the lookup failed and the stream was ended with an error line.
+*/
+
+func (s *ndjsonStream) convert(chunk []streamRecord) bool {
+	if len(chunk) == 0 {
+		return true
+	}
+	var webURLs, deepLinks []string
+	for _, record := range chunk {
+		if record.err != nil {
+			continue
+		}
+		if record.link.WebUrl != "" {
+			webURLs = append(webURLs, record.link.WebUrl)
+		} else {
+			deepLinks = append(deepLinks, record.link.Deeplink)
+		}
+	}
+	toDeepLink, err := s.api.ConverterService.BatchDeepLinks(webURLs)
+	var toWebURL []service.BatchResult
+	if err == nil {
+		toWebURL, err = s.api.ConverterService.BatchWebURLs(deepLinks)
+	}
+	if err != nil {
+		s.fail(chunk[0].line, http.StatusInternalServerError, err.Error())
+		return false
+	}
+
+	for _, record := range chunk {
+		item := StreamItem{Line: record.line}
+		switch {
+		case record.err != nil:
+			item.BatchItem = BatchItem{WebUrl: record.link.WebUrl, Deeplink: record.link.Deeplink, Error: record.err}
+		case record.link.WebUrl != "":
+			result := toDeepLink[0]
+			toDeepLink = toDeepLink[1:]
+			item.BatchItem = batchItem(result, true)
+		default:
+			result := toWebURL[0]
+			toWebURL = toWebURL[1:]
+			item.BatchItem = batchItem(result, false)
+		}
+		s.write(item)
+	}
+	s.flush()
+	return true
+}
+
+func (s *ndjsonStream) write(item StreamItem) {
+	s.converted++
+	if item.Error != nil {
+		s.failed++
+	} else if item.Created {
+		s.created++
+	}
+	_ = s.encoder.Encode(item)
+}
+
+/*
+Ends the stream with an error line, the lines from the given one on are not converted.
+*/
+
+func (s *ndjsonStream) fail(line int, code int, message string) {
+	_ = s.api.ConverterService.InsertLog(message)
+	_ = s.encoder.Encode(StreamItem{Line: line, BatchItem: BatchItem{Error: &ErrorResponse{Code: code, Status: "Error", Message: message}}})
+	s.flush()
+}
+
+func (s *ndjsonStream) log() {
+	_ = s.api.ConverterService.InsertLog("Stream of " + strconv.Itoa(s.converted) + " links converted. Created= " +
+		strconv.Itoa(s.created) + " Failed= " + strconv.Itoa(s.failed) + ".")
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"trendyolcase/pkg/repository/link"
+	"trendyolcase/pkg/service"
+)
+
+func streamLinks(t *testing.T, c ConverterAPI, body string) (*httptest.ResponseRecorder, []StreamItem) {
+	w := httptest.NewRecorder()
+	c.StreamLinks()(w, httptest.NewRequest(http.MethodPost, "/v1/links:stream", strings.NewReader(body)))
+	var items []StreamItem
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var item StreamItem
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+	}
+	return w, items
+}
+
+func TestStreamLinks(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterAPI(service.NewConverterService(link.NewMemoryRepository()))
+
+	w, items := streamLinks(t, c, `{"weburl": "https://www.trendyol.com/sr?q=saat"}
+{"weburl": 
+{"deeplink": "ty://?Page=Basket"}
+
+{}
+{"weburl": "https://www.trendyol.com/sr?q=etek"}
+`)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Equal(5, len(items))
+	lines := make([]int, len(items))
+	for i, item := range items {
+		lines[i] = item.Line
+	}
+	assert.Equal([]int{1, 2, 3, 5, 6}, lines)
+	assert.Equal("ty://?Page=Search&Query=saat", items[0].Deeplink)
+	assert.Equal(http.StatusBadRequest, items[1].Error.Code)
+	assert.Equal("https://www.trendyol.com/sepet", items[2].WebUrl)
+	assert.NotNil(items[3].Error)
+	assert.Equal("ty://?Page=Search&Query=etek", items[4].Deeplink)
+	assert.Nil(items[4].Error)
+
+	// Records before the size limit are converted, the stream ends with a 413 line.
+	c.MaxStreamSize = 60
+	_, items = streamLinks(t, c, `{"weburl": "https://www.trendyol.com/sr?q=saat"}
+{"weburl": "https://www.trendyol.com/sr?q=etek"}
+`)
+	assert.Equal(2, len(items))
+	assert.Equal("ty://?Page=Search&Query=saat", items[0].Deeplink)
+	assert.Equal(2, items[1].Line)
+	assert.Equal(http.StatusRequestEntityTooLarge, items[1].Error.Code)
+}
+
+func TestStreamLinksOverHTTP(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterAPI(service.NewConverterService(link.NewMemoryRepository()))
+	var body strings.Builder
+	records := 3*streamChunkSize + 7
+	for i := 0; i < records; i++ {
+		fmt.Fprintf(&body, `{"deeplink": "ty://?Page=Product&ContentId=%d"}`+"\n", i+1)
+	}
+
+	// HTTP/1.x responses are written after the body is read, HTTP/2 ones while it is read.
+	for _, http2 := range []bool{false, true} {
+		server := httptest.NewUnstartedServer(c.StreamLinks())
+		server.EnableHTTP2 = http2
+		server.StartTLS()
+		response, err := server.Client().Post(server.URL, "application/x-ndjson", strings.NewReader(body.String()))
+		if !assert.Nil(err) {
+			server.Close()
+			continue
+		}
+		assert.Equal(http2, response.ProtoMajor == 2)
+		scanner := bufio.NewScanner(response.Body)
+		lines := 0
+		for scanner.Scan() {
+			var item StreamItem
+			assert.Nil(json.Unmarshal(scanner.Bytes(), &item))
+			assert.Nil(item.Error, scanner.Text())
+			lines++
+		}
+		assert.Equal(records, lines)
+		response.Body.Close()
+		server.Close()
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+/*
+Serves /.well-known/apple-app-site-association, generated from the active rules so that iOS opens the app
+for the same web paths the converter turns into app pages. 404 when the rules have no ios.appIDs.
+*/
+
+func (c ConverterAPI) AppleAppSiteAssociation() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := c.pinRules(w)
+		association, err := c.ConverterService.AppleAppSiteAssociation()
+		respondAssociationFile(w, association, err)
+	}
+}
+
+/*
+Serves /.well-known/assetlinks.json for Android App Links. 404 when the rules have no android.sha256CertFingerprints.
+*/
+
+func (c ConverterAPI) AssetLinks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := c.pinRules(w)
+		links, err := c.ConverterService.AssetLinks()
+		respondAssociationFile(w, links, err)
+	}
+}
+
+/*
+Apple and Google fetch the files without following redirects and expect application/json.
+*/
+
+func respondAssociationFile(w http.ResponseWriter, file interface{}, err error) {
+	if err != nil {
+		RespondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	payload, err := json.Marshal(file)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
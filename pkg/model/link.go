@@ -1,6 +1,37 @@
 package model
 
+import "time"
+
 type Link struct {
 	WebUrl   string `json:"weburl"`
 	Deeplink string `json:"deeplink"`
 }
+
+const (
+	SourceWebURL   = "weburl"
+	SourceDeepLink = "deeplink"
+)
+
+/*
+Mapping is a stored link together with how it was produced: the attribution of the request that created it,
+the converter version and the side (SourceWebURL or SourceDeepLink) that was converted.
+*/
+
+type Mapping struct {
+	Link
+	Attribution      string
+	ConverterVersion string
+	Source           string
+}
+
+/*
+Click is a smart redirect of a phone to a deeplink. The app claims it after being installed by the token,
+or by the fingerprint of the phone when the token didn't survive the install.
+*/
+
+type Click struct {
+	Token       string
+	Fingerprint string
+	Deeplink    string
+	CreatedAt   time.Time
+}
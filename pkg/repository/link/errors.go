@@ -0,0 +1,53 @@
+package link
+
+import (
+	"errors"
+	"fmt"
+	"trendyolcase/pkg/model"
+)
+
+var (
+	ErrConflict  = errors.New("Link is already mapped to another link.")
+	ErrWrite     = errors.New("Link could not be saved.")
+	ErrNotFound  = errors.New("Link is not stored.")
+	ErrCodeTaken = errors.New("Code is already used by another link.")
+)
+
+/*
+ConflictError is returned by GetOrCreate when the webURL or deeplink is already stored with another pair.
+Stored is the pair in the links table, it is the canonical one.
+*/
+
+type ConflictError struct {
+	Requested model.Link
+	Stored    model.Link
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("WebURL= %s and deeplink= %s conflict with the stored pair WebURL= %s deeplink= %s.",
+		e.Requested.WebUrl, e.Requested.Deeplink, e.Stored.WebUrl, e.Stored.Deeplink)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+/*
+WriteError is returned by GetOrCreate when the links table could not be written or read back.
+*/
+
+type WriteError struct {
+	Err error
+}
+
+func (e *WriteError) Error() string {
+	return ErrWrite.Error() + " " + e.Err.Error()
+}
+
+func (e *WriteError) Is(target error) bool {
+	return target == ErrWrite
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
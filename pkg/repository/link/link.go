@@ -3,6 +3,8 @@ package link
 import (
 	"database/sql"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 	"trendyolcase/pkg/model"
 )
@@ -41,7 +43,7 @@ If the deeplink in the db, return the corresponding webURL.
 
 func (l *Repository) GetWebURLIfDeepLinkExist(deepLink string) (string, error) {
 	link := new(model.Link)
-	q, _ := l.db.Prepare("select long_url from links where short_url = $1")
+	q, _ := l.db.Prepare("select long_url from links where short_url = $1 order by long_url limit 1")
 	err := q.QueryRow(deepLink).Scan(&link.WebUrl)
 	if err != nil {
 		return "", nil
@@ -51,16 +53,270 @@ func (l *Repository) GetWebURLIfDeepLinkExist(deepLink string) (string, error) {
 }
 
 /*
-Adds weblink - webURL pairs to db.
+Returns the stored deeplinks of the webURLs keyed by webURL, with one query. WebURLs which are not stored are left out.
 */
 
-func (l *Repository) Insert(webURL string, deepLink string) bool {
-	q, _ := l.db.Prepare("insert into links(long_url,short_url) values($1,$2)")
-	_, err := q.Exec(webURL, deepLink)
+func (l *Repository) GetDeepLinks(webURLs []string) (map[string]string, error) {
+	return l.lookup("select long_url, short_url from links where long_url in ", webURLs, false)
+}
+
+/*
+Returns the stored webURLs of the deeplinks keyed by deeplink, with one query. Deeplinks which are not stored are left out.
+A deeplink stored more than once gives the smallest of its webURLs, like GetWebURLIfDeepLinkExist.
+*/
+
+func (l *Repository) GetWebURLs(deepLinks []string) (map[string]string, error) {
+	return l.lookup("select long_url, short_url from links where short_url in ", deepLinks, true)
+}
+
+func (l *Repository) lookup(query string, keys []string, byDeepLink bool) (map[string]string, error) {
+	found := map[string]string{}
+	if len(keys) == 0 {
+		return found, nil
+	}
+	placeholders := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+		args[i] = key
+	}
+	rows, err := l.db.Query(query+"("+strings.Join(placeholders, ",")+")", args...)
 	if err != nil {
-		return false
-	} else {
-		return true
+		return nil, errors.New("Database connection or query has problem.")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var link model.Link
+		if err := rows.Scan(&link.WebUrl, &link.Deeplink); err != nil {
+			return nil, err
+		}
+		if byDeepLink {
+			if _, ok := found[link.Deeplink]; !ok || link.WebUrl < found[link.Deeplink] {
+				found[link.Deeplink] = link.WebUrl
+			}
+		} else {
+			found[link.WebUrl] = link.Deeplink
+		}
+	}
+	return found, rows.Err()
+}
+
+/*
+Stores the webURL - deeplink pair together with the attribution parameters (utm_*, gclid...) of the request that created it
+and the converter version that produced it, unless the webURL or the deeplink is already stored. Only the webURL column is unique, so concurrent
+requests store one pair of a webURL, while two webURLs racing for a deeplink may both be stored and the reverse lookups pick the smallest webURL.
+The stored pair is returned, a *ConflictError tells that it is not the requested one and a *WriteError that nothing could be stored.
+*/
+
+func (l *Repository) GetOrCreate(m model.Mapping) (model.Link, error) {
+	requested := m.Link
+	result, err := l.db.Exec("insert into links(long_url,short_url,attribution,converter_version,source) select $1,$2,$3,$4,$5 where not exists (select 1 from links where short_url = $2) on conflict do nothing",
+		m.WebUrl, m.Deeplink, m.Attribution, m.ConverterVersion, m.Source)
+	if err != nil {
+		return model.Link{}, &WriteError{Err: err}
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 1 {
+		return requested, nil
+	}
+
+	rows, err := l.db.Query("select long_url, short_url from links where long_url = $1 or short_url = $2 order by long_url", m.WebUrl, m.Deeplink)
+	if err != nil {
+		return model.Link{}, &WriteError{Err: err}
+	}
+	defer rows.Close()
+	var stored []model.Link
+	for rows.Next() {
+		var link model.Link
+		if err := rows.Scan(&link.WebUrl, &link.Deeplink); err != nil {
+			return model.Link{}, &WriteError{Err: err}
+		}
+		stored = append(stored, link)
+	}
+	if err := rows.Err(); err != nil {
+		return model.Link{}, &WriteError{Err: err}
+	}
+	return resolveStored(requested, stored)
+}
+
+/*
+Picks the canonical pair among the rows conflicting with the requested one, the row of the same webURL comes first.
+*/
+
+func resolveStored(requested model.Link, stored []model.Link) (model.Link, error) {
+	if len(stored) == 0 {
+		return model.Link{}, &WriteError{Err: errors.New("Link was neither inserted nor found.")}
+	}
+	canonical := stored[0]
+	for _, link := range stored {
+		if link.WebUrl == requested.WebUrl {
+			canonical = link
+		}
+	}
+	if canonical == requested {
+		return canonical, nil
+	}
+	return canonical, &ConflictError{Requested: requested, Stored: canonical}
+}
+
+/*
+Returns the mappings produced by another converter version than the given one.
+*/
+
+func (l *Repository) StaleMappings(converterVersion string) ([]model.Mapping, error) {
+	return l.mappings("select long_url, short_url, attribution, converter_version, source from links where converter_version <> $1 order by long_url", converterVersion)
+}
+
+/*
+Returns the mappings which have no short code yet.
+*/
+
+func (l *Repository) MappingsWithoutCode() ([]model.Mapping, error) {
+	return l.mappings("select long_url, short_url, attribution, converter_version, source from links where code is null order by long_url")
+}
+
+func (l *Repository) mappings(query string, args ...interface{}) ([]model.Mapping, error) {
+	rows, err := l.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var mappings []model.Mapping
+	for rows.Next() {
+		var m model.Mapping
+		if err := rows.Scan(&m.WebUrl, &m.Deeplink, &m.Attribution, &m.ConverterVersion, &m.Source); err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, rows.Err()
+}
+
+/*
+Rewrites the mapping stored for webURL. The attribution of the row is kept. A *ConflictError is returned
+when the new webURL or deeplink is already stored by another row, and a *WriteError when the row could not be written.
+*/
+
+func (l *Repository) UpdateMapping(webURL string, m model.Mapping) error {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return &WriteError{Err: err}
+	}
+	defer tx.Rollback()
+
+	var stored model.Link
+	err = tx.QueryRow("select long_url, short_url from links where (long_url = $1 or short_url = $2) and long_url <> $3",
+		m.WebUrl, m.Deeplink, webURL).Scan(&stored.WebUrl, &stored.Deeplink)
+	if err == nil {
+		return &ConflictError{Requested: m.Link, Stored: stored}
+	}
+	if err != sql.ErrNoRows {
+		return &WriteError{Err: err}
+	}
+	result, err := tx.Exec("update links set long_url = $1, short_url = $2, converter_version = $3, source = $4 where long_url = $5",
+		m.WebUrl, m.Deeplink, m.ConverterVersion, m.Source, webURL)
+	if err != nil {
+		return &WriteError{Err: err}
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return &WriteError{Err: errors.New("WebURL= " + webURL + " is not stored.")}
+	}
+	if err := tx.Commit(); err != nil {
+		return &WriteError{Err: err}
+	}
+	return nil
+}
+
+/*
+Gives the mapping stored for webURL the short code, unless it already has one, and returns the code of the mapping.
+ErrNotFound is returned when webURL is not stored and ErrCodeTaken when another mapping has the code.
+*/
+
+func (l *Repository) AssignCode(webURL string, code string) (string, error) {
+	result, err := l.db.Exec("update links set code = $1 where long_url = $2 and code is null and not exists (select 1 from links where code = $1)", code, webURL)
+	if err != nil {
+		return "", &WriteError{Err: err}
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 1 {
+		return code, nil
+	}
+	var stored sql.NullString
+	err = l.db.QueryRow("select code from links where long_url = $1", webURL).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", &WriteError{Err: err}
+	}
+	if stored.Valid {
+		return stored.String, nil
+	}
+	return "", ErrCodeTaken
+}
+
+/*
+Returns the link the short code belongs to, ErrNotFound when no mapping has it.
+*/
+
+func (l *Repository) GetByCode(code string) (model.Link, error) {
+	var link model.Link
+	err := l.db.QueryRow("select long_url, short_url from links where code = $1", code).Scan(&link.WebUrl, &link.Deeplink)
+	if err == sql.ErrNoRows {
+		return model.Link{}, ErrNotFound
+	}
+	if err != nil {
+		return model.Link{}, errors.New("Database connection or query has problem.")
+	}
+	return link, nil
+}
+
+/*
+Stores the click so that the app can claim it after being installed.
+*/
+
+func (l *Repository) RecordClick(click model.Click) error {
+	_, err := l.db.Exec("insert into clicks(token,fingerprint,deeplink,created_at) values($1,$2,$3,$4)",
+		click.Token, click.Fingerprint, click.Deeplink, click.CreatedAt.UTC())
+	if err != nil {
+		return &WriteError{Err: err}
+	}
+	return nil
+}
+
+/*
+Claims the click of the token if it was created at or after since. A click is claimed once, ErrNotFound is returned
+when there is no such click.
+*/
+
+func (l *Repository) ClaimClickByToken(token string, since time.Time) (model.Click, error) {
+	return l.claimClick("select token, fingerprint, deeplink, created_at from clicks where token = $1 and claimed_at is null and created_at >= $2", token, since)
+}
+
+/*
+Claims the latest click of the fingerprint created at or after since, like ClaimClickByToken.
+*/
+
+func (l *Repository) ClaimClickByFingerprint(fingerprint string, since time.Time) (model.Click, error) {
+	return l.claimClick("select token, fingerprint, deeplink, created_at from clicks where fingerprint = $1 and claimed_at is null and created_at >= $2 order by created_at desc limit 1", fingerprint, since)
+}
+
+func (l *Repository) claimClick(query string, key string, since time.Time) (model.Click, error) {
+	// A concurrent claim can take the selected click first, the query is repeated then.
+	for {
+		var click model.Click
+		err := l.db.QueryRow(query, key, since.UTC()).Scan(&click.Token, &click.Fingerprint, &click.Deeplink, &click.CreatedAt)
+		if err == sql.ErrNoRows {
+			return model.Click{}, ErrNotFound
+		}
+		if err != nil {
+			return model.Click{}, errors.New("Database connection or query has problem.")
+		}
+		result, err := l.db.Exec("update clicks set claimed_at = $1 where token = $2 and claimed_at is null", time.Now().UTC(), click.Token)
+		if err != nil {
+			return model.Click{}, &WriteError{Err: err}
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 1 {
+			return click, nil
+		}
 	}
 }
 
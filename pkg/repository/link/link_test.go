@@ -0,0 +1,244 @@
+package link
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"trendyolcase/pkg/model"
+)
+
+type linkRepository interface {
+	GetDeepLinkIfWebURLExist(webURL string) (string, error)
+	GetWebURLIfDeepLinkExist(deepLink string) (string, error)
+	GetOrCreate(m model.Mapping) (model.Link, error)
+	InsertLog(logInformation string) bool
+}
+
+/*
+Backends which run without a database server. Postgres uses the same *Repository as SQLite.
+*/
+
+func testRepositories(t *testing.T) map[string]linkRepository {
+	sqlite, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "links.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]linkRepository{
+		"memory": NewMemoryRepository(),
+		"sqlite": sqlite,
+	}
+}
+
+func TestRepository(t *testing.T) {
+	for name, r := range testRepositories(t) {
+		assert := assert.New(t)
+
+		_, err := r.GetDeepLinkIfWebURLExist("https://www.trendyol.com/sr?q=elbise")
+		assert.NotNil(err, name)
+		webURL, err := r.GetWebURLIfDeepLinkExist("ty://?Page=Search&Query=elbise")
+		assert.Nil(err, name)
+		assert.Equal("", webURL, name)
+
+		stored, err := r.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=elbise", Deeplink: "ty://?Page=Search&Query=elbise"}, Attribution: "utm_source=a"})
+		assert.Nil(err, name)
+		assert.Equal("ty://?Page=Search&Query=elbise", stored.Deeplink, name)
+
+		deepLink, err := r.GetDeepLinkIfWebURLExist("https://www.trendyol.com/sr?q=elbise")
+		assert.Nil(err, name)
+		assert.Equal("ty://?Page=Search&Query=elbise", deepLink, name)
+		webURL, err = r.GetWebURLIfDeepLinkExist("ty://?Page=Search&Query=elbise")
+		assert.Nil(err, name)
+		assert.Equal("https://www.trendyol.com/sr?q=elbise", webURL, name)
+
+		assert.True(r.InsertLog("test log"), name)
+	}
+}
+
+func TestGetOrCreate(t *testing.T) {
+	for name, r := range testRepositories(t) {
+		assert := assert.New(t)
+		product := model.Link{WebUrl: "https://www.trendyol.com/casio/saat-p-1925865", Deeplink: "ty://?Page=Product&ContentId=1925865"}
+
+		stored, err := r.GetOrCreate(model.Mapping{Link: product})
+		assert.Nil(err, name)
+		assert.Equal(product, stored, name)
+		stored, err = r.GetOrCreate(model.Mapping{Link: product})
+		assert.Nil(err, name)
+		assert.Equal(product, stored, name)
+
+		// Same product with other slugs, the deeplink belongs to the first webURL.
+		stored, err = r.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/brand/name-p-1925865", Deeplink: product.Deeplink}})
+		var conflict *ConflictError
+		assert.True(errors.As(err, &conflict), name)
+		assert.True(errors.Is(err, ErrConflict), name)
+		assert.Equal(product, stored, name)
+		assert.Equal(product, conflict.Stored, name)
+
+		stored, err = r.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: product.WebUrl, Deeplink: "ty://?Page=Product&ContentId=1"}})
+		assert.True(errors.Is(err, ErrConflict), name)
+		assert.Equal(product, stored, name)
+	}
+}
+
+func TestGetOrCreateConcurrently(t *testing.T) {
+	for name, r := range testRepositories(t) {
+		assert := assert.New(t)
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := r.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=saat", Deeplink: "ty://?Page=Search&Query=saat"}})
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			assert.Nil(err, name)
+		}
+	}
+}
+
+func TestStaleMappingsAndUpdateMapping(t *testing.T) {
+	for name, r := range testRepositories(t) {
+		assert := assert.New(t)
+		backfill := r.(interface {
+			StaleMappings(converterVersion string) ([]model.Mapping, error)
+			UpdateMapping(webURL string, m model.Mapping) error
+		})
+		_, err := r.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=saat", Deeplink: "ty://?Page=Home"}, Attribution: "utm_source=a", ConverterVersion: "0/1"})
+		assert.Nil(err, name)
+		_, err = r.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=etek", Deeplink: "ty://?Page=Search&Query=etek"}, ConverterVersion: "1/1"})
+		assert.Nil(err, name)
+
+		stale, err := backfill.StaleMappings("1/1")
+		assert.Nil(err, name)
+		assert.Equal([]model.Mapping{{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=saat", Deeplink: "ty://?Page=Home"}, Attribution: "utm_source=a", ConverterVersion: "0/1"}}, stale, name)
+
+		err = backfill.UpdateMapping("https://www.trendyol.com/sr?q=saat", model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=saat", Deeplink: "ty://?Page=Search&Query=etek"}, ConverterVersion: "1/1"})
+		assert.True(errors.Is(err, ErrConflict), name)
+		err = backfill.UpdateMapping("https://www.trendyol.com/sr?q=saat", model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=saat", Deeplink: "ty://?Page=Search&Query=saat"}, ConverterVersion: "1/1", Source: model.SourceWebURL})
+		assert.Nil(err, name)
+		err = backfill.UpdateMapping("https://www.trendyol.com/sr?q=yok", model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=yok", Deeplink: "ty://?Page=Search&Query=yok"}})
+		assert.True(errors.Is(err, ErrWrite), name)
+
+		webURL, err := r.GetWebURLIfDeepLinkExist("ty://?Page=Search&Query=saat")
+		assert.Nil(err, name)
+		assert.Equal("https://www.trendyol.com/sr?q=saat", webURL, name)
+		stale, err = backfill.StaleMappings("1/1")
+		assert.Nil(err, name)
+		assert.Equal(0, len(stale), name)
+	}
+}
+
+func TestBatchLookups(t *testing.T) {
+	for name, r := range testRepositories(t) {
+		assert := assert.New(t)
+		batch := r.(interface {
+			GetDeepLinks(webURLs []string) (map[string]string, error)
+			GetWebURLs(deepLinks []string) (map[string]string, error)
+		})
+		_, err := r.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=saat", Deeplink: "ty://?Page=Search&Query=saat"}})
+		assert.Nil(err, name)
+
+		found, err := batch.GetDeepLinks([]string{"https://www.trendyol.com/sr?q=saat", "https://www.trendyol.com/sr?q=etek"})
+		assert.Nil(err, name)
+		assert.Equal(map[string]string{"https://www.trendyol.com/sr?q=saat": "ty://?Page=Search&Query=saat"}, found, name)
+		found, err = batch.GetWebURLs([]string{"ty://?Page=Search&Query=saat"})
+		assert.Nil(err, name)
+		assert.Equal(map[string]string{"ty://?Page=Search&Query=saat": "https://www.trendyol.com/sr?q=saat"}, found, name)
+		found, err = batch.GetWebURLs(nil)
+		assert.Nil(err, name)
+		assert.Equal(0, len(found), name)
+	}
+}
+
+func TestShortCodes(t *testing.T) {
+	for name, r := range testRepositories(t) {
+		assert := assert.New(t)
+		codes := r.(interface {
+			AssignCode(webURL string, code string) (string, error)
+			GetByCode(code string) (model.Link, error)
+			MappingsWithoutCode() ([]model.Mapping, error)
+			UpdateMapping(webURL string, m model.Mapping) error
+		})
+		saat := model.Link{WebUrl: "https://www.trendyol.com/sr?q=saat", Deeplink: "ty://?Page=Search&Query=saat"}
+		etek := model.Link{WebUrl: "https://www.trendyol.com/sr?q=etek", Deeplink: "ty://?Page=Search&Query=etek"}
+		_, err := r.GetOrCreate(model.Mapping{Link: saat})
+		assert.Nil(err, name)
+		_, err = r.GetOrCreate(model.Mapping{Link: etek})
+		assert.Nil(err, name)
+
+		code, err := codes.AssignCode(saat.WebUrl, "aB3")
+		assert.Nil(err, name)
+		assert.Equal("aB3", code, name)
+		// A mapping keeps its first code.
+		code, err = codes.AssignCode(saat.WebUrl, "xY9")
+		assert.Nil(err, name)
+		assert.Equal("aB3", code, name)
+		_, err = codes.AssignCode(etek.WebUrl, "aB3")
+		assert.True(errors.Is(err, ErrCodeTaken), name)
+		_, err = codes.AssignCode("https://www.trendyol.com/sr?q=yok", "yok")
+		assert.True(errors.Is(err, ErrNotFound), name)
+
+		uncoded, err := codes.MappingsWithoutCode()
+		assert.Nil(err, name)
+		assert.Equal([]model.Mapping{{Link: etek}}, uncoded, name)
+
+		link, err := codes.GetByCode("aB3")
+		assert.Nil(err, name)
+		assert.Equal(saat, link, name)
+		_, err = codes.GetByCode("ab3")
+		assert.True(errors.Is(err, ErrNotFound), name)
+
+		// Rewritten mappings keep their code.
+		saatURL := model.Link{WebUrl: "https://www.trendyol.com/sr?q=saatler", Deeplink: saat.Deeplink}
+		assert.Nil(codes.UpdateMapping(saat.WebUrl, model.Mapping{Link: saatURL}), name)
+		link, err = codes.GetByCode("aB3")
+		assert.Nil(err, name)
+		assert.Equal(saatURL, link, name)
+	}
+}
+
+func TestClaimClick(t *testing.T) {
+	for name, r := range testRepositories(t) {
+		assert := assert.New(t)
+		clicks := r.(interface {
+			RecordClick(click model.Click) error
+			ClaimClickByToken(token string, since time.Time) (model.Click, error)
+			ClaimClickByFingerprint(fingerprint string, since time.Time) (model.Click, error)
+		})
+		now := time.Now().UTC().Truncate(time.Second)
+		old := model.Click{Token: "t1", Fingerprint: "f", Deeplink: "ty://?Page=Search&Query=saat", CreatedAt: now.Add(-2 * time.Hour)}
+		first := model.Click{Token: "t2", Fingerprint: "f", Deeplink: "ty://?Page=Search&Query=etek", CreatedAt: now.Add(-time.Minute)}
+		latest := model.Click{Token: "t3", Fingerprint: "f", Deeplink: "ty://?Page=Search&Query=elbise", CreatedAt: now}
+		for _, click := range []model.Click{old, first, latest} {
+			assert.Nil(clicks.RecordClick(click), name)
+		}
+		since := now.Add(-time.Hour)
+
+		_, err := clicks.ClaimClickByToken("t1", since)
+		assert.True(errors.Is(err, ErrNotFound), name)
+		claimed, err := clicks.ClaimClickByToken("t2", since)
+		assert.Nil(err, name)
+		assert.Equal(first.Deeplink, claimed.Deeplink, name)
+		assert.True(first.CreatedAt.Equal(claimed.CreatedAt), name)
+		_, err = clicks.ClaimClickByToken("t2", since)
+		assert.True(errors.Is(err, ErrNotFound), name)
+
+		// The latest unclaimed click of the fingerprint in the window is claimed.
+		claimed, err = clicks.ClaimClickByFingerprint("f", since)
+		assert.Nil(err, name)
+		assert.Equal("t3", claimed.Token, name)
+		_, err = clicks.ClaimClickByFingerprint("f", since)
+		assert.True(errors.Is(err, ErrNotFound), name)
+		claimed, err = clicks.ClaimClickByFingerprint("f", now.Add(-3*time.Hour))
+		assert.Nil(err, name)
+		assert.Equal("t1", claimed.Token, name)
+	}
+}
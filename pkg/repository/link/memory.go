@@ -0,0 +1,252 @@
+package link
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+	"trendyolcase/pkg/model"
+)
+
+/*
+MemoryRepository keeps links and logs in memory. It is meant for tests and local development,
+everything is lost when the process stops.
+*/
+
+type MemoryRepository struct {
+	mu         sync.RWMutex
+	byWebURL   map[string]*model.Mapping
+	byDeepLink map[string]*model.Mapping
+	// Short codes, code -> webURL and webURL -> code.
+	byCode map[string]string
+	codes  map[string]string
+	clicks []memoryClick
+	logs   []Log
+}
+
+type memoryClick struct {
+	model.Click
+	claimed bool
+}
+
+type Log struct {
+	CreatedAt time.Time
+	Info      string
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		byWebURL:   map[string]*model.Mapping{},
+		byDeepLink: map[string]*model.Mapping{},
+		byCode:     map[string]string{},
+		codes:      map[string]string{},
+	}
+}
+
+func (m *MemoryRepository) GetDeepLinkIfWebURLExist(webURL string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mapping, ok := m.byWebURL[webURL]
+	if !ok {
+		return "", errors.New("Database connection or query has problem.")
+	}
+	return mapping.Deeplink, nil
+}
+
+func (m *MemoryRepository) GetWebURLIfDeepLinkExist(deepLink string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if mapping, ok := m.byDeepLink[deepLink]; ok {
+		return mapping.WebUrl, nil
+	}
+	return "", nil
+}
+
+func (m *MemoryRepository) GetDeepLinks(webURLs []string) (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	found := map[string]string{}
+	for _, webURL := range webURLs {
+		if mapping, ok := m.byWebURL[webURL]; ok {
+			found[webURL] = mapping.Deeplink
+		}
+	}
+	return found, nil
+}
+
+func (m *MemoryRepository) GetWebURLs(deepLinks []string) (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	found := map[string]string{}
+	for _, deepLink := range deepLinks {
+		if mapping, ok := m.byDeepLink[deepLink]; ok {
+			found[deepLink] = mapping.WebUrl
+		}
+	}
+	return found, nil
+}
+
+/*
+Like Repository.GetOrCreate, a pair isn't stored when its webURL or deeplink is already stored.
+*/
+
+func (m *MemoryRepository) GetOrCreate(mapping model.Mapping) (model.Link, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stored := m.conflicting(mapping.Link, ""); len(stored) > 0 {
+		return resolveStored(mapping.Link, stored)
+	}
+	m.byWebURL[mapping.WebUrl] = &mapping
+	m.byDeepLink[mapping.Deeplink] = &mapping
+	return mapping.Link, nil
+}
+
+/*
+Returns the stored pairs sharing the webURL or the deeplink of link, except the one stored for exceptWebURL.
+*/
+
+func (m *MemoryRepository) conflicting(link model.Link, exceptWebURL string) []model.Link {
+	var stored []model.Link
+	if mapping, ok := m.byWebURL[link.WebUrl]; ok && mapping.WebUrl != exceptWebURL {
+		stored = append(stored, mapping.Link)
+	}
+	if mapping, ok := m.byDeepLink[link.Deeplink]; ok && mapping.WebUrl != exceptWebURL {
+		stored = append(stored, mapping.Link)
+	}
+	return stored
+}
+
+func (m *MemoryRepository) StaleMappings(converterVersion string) ([]model.Mapping, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var mappings []model.Mapping
+	for _, mapping := range m.byWebURL {
+		if mapping.ConverterVersion != converterVersion {
+			mappings = append(mappings, *mapping)
+		}
+	}
+	sort.Slice(mappings, func(i, j int) bool { return mappings[i].WebUrl < mappings[j].WebUrl })
+	return mappings, nil
+}
+
+func (m *MemoryRepository) UpdateMapping(webURL string, mapping model.Mapping) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.byWebURL[webURL]
+	if !ok {
+		return &WriteError{Err: errors.New("WebURL= " + webURL + " is not stored.")}
+	}
+	if stored := m.conflicting(mapping.Link, webURL); len(stored) > 0 {
+		return &ConflictError{Requested: mapping.Link, Stored: stored[0]}
+	}
+	delete(m.byWebURL, old.WebUrl)
+	delete(m.byDeepLink, old.Deeplink)
+	mapping.Attribution = old.Attribution
+	m.byWebURL[mapping.WebUrl] = &mapping
+	m.byDeepLink[mapping.Deeplink] = &mapping
+	if code, ok := m.codes[webURL]; ok {
+		delete(m.codes, webURL)
+		m.codes[mapping.WebUrl] = code
+		m.byCode[code] = mapping.WebUrl
+	}
+	return nil
+}
+
+func (m *MemoryRepository) MappingsWithoutCode() ([]model.Mapping, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var mappings []model.Mapping
+	for webURL, mapping := range m.byWebURL {
+		if _, ok := m.codes[webURL]; !ok {
+			mappings = append(mappings, *mapping)
+		}
+	}
+	sort.Slice(mappings, func(i, j int) bool { return mappings[i].WebUrl < mappings[j].WebUrl })
+	return mappings, nil
+}
+
+/*
+See Repository.AssignCode.
+*/
+
+func (m *MemoryRepository) AssignCode(webURL string, code string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.byWebURL[webURL]; !ok {
+		return "", ErrNotFound
+	}
+	if stored, ok := m.codes[webURL]; ok {
+		return stored, nil
+	}
+	if _, ok := m.byCode[code]; ok {
+		return "", ErrCodeTaken
+	}
+	m.byCode[code] = webURL
+	m.codes[webURL] = code
+	return code, nil
+}
+
+func (m *MemoryRepository) GetByCode(code string) (model.Link, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	webURL, ok := m.byCode[code]
+	if !ok {
+		return model.Link{}, ErrNotFound
+	}
+	return m.byWebURL[webURL].Link, nil
+}
+
+func (m *MemoryRepository) RecordClick(click model.Click) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clicks = append(m.clicks, memoryClick{Click: click})
+	return nil
+}
+
+/*
+See Repository.ClaimClickByToken.
+*/
+
+func (m *MemoryRepository) ClaimClickByToken(token string, since time.Time) (model.Click, error) {
+	return m.claimClick(func(click model.Click) bool { return click.Token == token }, since)
+}
+
+/*
+See Repository.ClaimClickByFingerprint.
+*/
+
+func (m *MemoryRepository) ClaimClickByFingerprint(fingerprint string, since time.Time) (model.Click, error) {
+	return m.claimClick(func(click model.Click) bool { return click.Fingerprint == fingerprint }, since)
+}
+
+func (m *MemoryRepository) claimClick(match func(model.Click) bool, since time.Time) (model.Click, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	latest := -1
+	for i, click := range m.clicks {
+		if click.claimed || click.CreatedAt.Before(since) || !match(click.Click) {
+			continue
+		}
+		if latest == -1 || click.CreatedAt.After(m.clicks[latest].CreatedAt) {
+			latest = i
+		}
+	}
+	if latest == -1 {
+		return model.Click{}, ErrNotFound
+	}
+	m.clicks[latest].claimed = true
+	return m.clicks[latest].Click, nil
+}
+
+func (m *MemoryRepository) InsertLog(logInformation string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logs = append(m.logs, Log{CreatedAt: time.Now(), Info: logInformation})
+	return true
+}
+
+func (m *MemoryRepository) Logs() []Log {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Log(nil), m.logs...)
+}
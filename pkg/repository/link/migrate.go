@@ -0,0 +1,291 @@
+package link
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+/*
+Migration is one versioned schema change, read from 'migrations/<dialect>/<version>_<name>.up.sql' and its '.down.sql'.
+*/
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+/*
+A migration whose down file has only comments can't be rolled back, e.g. the baseline of databases
+which had the tables before the migrations.
+*/
+
+func (m Migration) Reversible() bool {
+	for _, line := range strings.Split(m.Down, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Checks run before the up of the migration of the same name. A failing check stops the migration with nothing changed,
+for data an operator has to look at first.
+*/
+
+var migrationChecks = map[string]func(db *sql.DB) error{
+	"unique_links": checkDuplicateLinks,
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+/*
+Migrator applies the embedded migrations of a dialect and keeps the applied versions in schema_migrations.
+*/
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("There are no migrations for %s.", dialect)
+	}
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		direction := ""
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		idx := strings.Index(base, "_")
+		if idx == -1 {
+			return nil, fmt.Errorf("Migration file name is invalid: %s.", name)
+		}
+		version, err := strconv.Atoi(base[:idx])
+		if err != nil {
+			return nil, fmt.Errorf("Migration file name is invalid: %s.", name)
+		}
+		b, err := migrationFiles.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: base[idx+1:]}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	var migrations []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("Migration %04d_%s needs both up and down files.", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func (m *Migrator) ensureTable() error {
+	_, err := m.db.Exec(`create table if not exists schema_migrations(
+		version    integer primary key,
+		name       text not null,
+		applied_at timestamp not null
+	)`)
+	return err
+}
+
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.Query("select version, applied_at from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+/*
+Lists every known migration with whether and when it was applied.
+*/
+
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var statuses []MigrationStatus
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, MigrationStatus{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+	}
+	return statuses, nil
+}
+
+/*
+Applies the pending migrations in version order, each one in its own transaction. Returns the applied ones.
+*/
+
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if check, ok := migrationChecks[migration.Name]; ok {
+			if err := check(m.db); err != nil {
+				return done, fmt.Errorf("Migration %04d_%s failed: %s", migration.Version, migration.Name, err)
+			}
+		}
+		err := m.inTx(migration.Up, "insert into schema_migrations(version,name,applied_at) values($1,$2,$3)",
+			migration.Version, migration.Name, time.Now())
+		if err != nil {
+			return done, fmt.Errorf("Migration %04d_%s failed: %s", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+/*
+Rolls back the most recently applied migration. Returns false when there is nothing to roll back,
+an irreversible migration is refused.
+*/
+
+func (m *Migrator) Down() (Migration, bool, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return Migration{}, false, err
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if !migration.Reversible() {
+			return migration, false, fmt.Errorf("%04d_%s can't be rolled back, it would drop data which existed before the migrations.", migration.Version, migration.Name)
+		}
+		err := m.inTx(migration.Down, "delete from schema_migrations where version = $1", migration.Version)
+		if err != nil {
+			return migration, false, fmt.Errorf("Rollback of %04d_%s failed: %s", migration.Version, migration.Name, err)
+		}
+		return migration, true, nil
+	}
+	return Migration{}, false, nil
+}
+
+/*
+Most duplicated webURLs listed by checkDuplicateLinks.
+*/
+
+const listedDuplicates = 20
+
+/*
+The unique index can't be created while a webURL is stored more than once. Which row is right isn't
+known here, so the duplicates are listed for the operator to remove, the most duplicated first.
+*/
+
+func checkDuplicateLinks(db *sql.DB) error {
+	rows, err := db.Query("select long_url, count(*) from links group by long_url having count(*) > 1 order by count(*) desc, long_url")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var duplicates []string
+	for rows.Next() {
+		var webURL string
+		var count int
+		if err := rows.Scan(&webURL, &count); err != nil {
+			return err
+		}
+		duplicates = append(duplicates, fmt.Sprintf("long_url=%s (%d rows)", webURL, count))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+	listed := duplicates
+	if len(listed) > listedDuplicates {
+		listed = append(listed[:listedDuplicates:listedDuplicates], fmt.Sprintf("and %d more", len(duplicates)-listedDuplicates))
+	}
+	return fmt.Errorf("links has duplicate rows, delete all but one row of each and run the migration again: %s.", strings.Join(listed, ", "))
+}
+
+func (m *Migrator) inTx(script string, record string, args ...interface{}) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(record, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
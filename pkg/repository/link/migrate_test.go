@@ -0,0 +1,112 @@
+package link
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"trendyolcase/pkg/model"
+)
+
+func TestMigrationsHaveSameVersions(t *testing.T) {
+	assert := assert.New(t)
+	postgres, err := loadMigrations(Postgres)
+	assert.Nil(err)
+	sqlite, err := loadMigrations(SQLite)
+	assert.Nil(err)
+	assert.Equal(len(postgres), len(sqlite))
+	for i := range postgres {
+		assert.Equal(postgres[i].Version, sqlite[i].Version)
+		assert.Equal(postgres[i].Name, sqlite[i].Name)
+		assert.Equal(postgres[i].Reversible(), sqlite[i].Reversible(), postgres[i].Name)
+		assert.Equal(postgres[i].Version > 2, postgres[i].Reversible(), postgres[i].Name)
+	}
+}
+
+func TestMigratorUpDownStatus(t *testing.T) {
+	assert := assert.New(t)
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "links.db"))
+	assert.Nil(err)
+	migrator, err := NewMigrator(db, SQLite)
+	assert.Nil(err)
+
+	applied, err := migrator.Up()
+	assert.Nil(err)
+	assert.Equal(len(migrator.migrations), len(applied))
+	applied, err = migrator.Up()
+	assert.Nil(err)
+	assert.Equal(0, len(applied))
+
+	statuses, err := migrator.Status()
+	assert.Nil(err)
+	for _, s := range statuses {
+		assert.True(s.Applied, s.Name)
+	}
+
+	r := NewRepository(db)
+	_, err = r.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=elbise", Deeplink: "ty://?Page=Search&Query=elbise"}})
+	assert.Nil(err)
+	_, err = db.Exec("insert into links(long_url,short_url) values($1,$2)", "https://www.trendyol.com/sr?q=elbise", "ty://?Page=Search&Query=etek")
+	assert.NotNil(err)
+	// Deeplinks may repeat, but GetOrCreate doesn't store a second webURL of a deeplink.
+	_, err = r.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=Elbise", Deeplink: "ty://?Page=Search&Query=elbise"}})
+	assert.IsType(&ConflictError{}, err)
+	_, err = db.Exec("insert into links(long_url,short_url) values($1,$2)", "https://www.trendyol.com/kampanyalar", "ty://?Page=Home")
+	assert.Nil(err)
+	_, err = db.Exec("insert into links(long_url,short_url) values($1,$2)", "https://www.trendyol.com/Hesabim/Adreslerim", "ty://?Page=Home")
+	assert.Nil(err)
+	webURL, err := r.GetWebURLIfDeepLinkExist("ty://?Page=Home")
+	assert.Nil(err)
+	assert.Equal("https://www.trendyol.com/Hesabim/Adreslerim", webURL)
+	_, err = db.Exec("delete from links where short_url = $1", "ty://?Page=Home")
+	assert.Nil(err)
+
+	m, ok, err := migrator.Down()
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal("create_clicks", m.Name)
+	m, ok, err = migrator.Down()
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal("links_code", m.Name)
+	m, ok, err = migrator.Down()
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal("links_converter_version", m.Name)
+	m, ok, err = migrator.Down()
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal("unique_links", m.Name)
+	_, err = db.Exec("insert into links(long_url,short_url) values($1,$2)", "https://www.trendyol.com/sr?q=elbise", "ty://?Page=Search&Query=etek")
+	assert.Nil(err)
+
+	// Duplicates inserted before the unique indexes stop the migration and are listed, nothing is deleted.
+	applied, err = migrator.Up()
+	assert.NotNil(err)
+	assert.Equal(0, len(applied))
+	assert.Contains(err.Error(), "long_url=https://www.trendyol.com/sr?q=elbise (2 rows)")
+	var count int
+	assert.Nil(db.QueryRow("select count(*) from links").Scan(&count))
+	assert.Equal(2, count)
+	_, err = db.Exec("delete from links where short_url = $1", "ty://?Page=Search&Query=etek")
+	assert.Nil(err)
+	applied, err = migrator.Up()
+	assert.Nil(err)
+	assert.Equal(4, len(applied))
+	deepLink, err := r.GetDeepLinkIfWebURLExist("https://www.trendyol.com/sr?q=elbise")
+	assert.Nil(err)
+	assert.Equal("ty://?Page=Search&Query=elbise", deepLink)
+
+	for ok {
+		m, ok, err = migrator.Down()
+	}
+	// The baseline keeps the tables of databases which existed before the migrations.
+	assert.NotNil(err)
+	assert.Equal("create_logs", m.Name)
+	statuses, err = migrator.Status()
+	assert.Nil(err)
+	for _, s := range statuses {
+		assert.Equal(s.Version <= 2, s.Applied, s.Name)
+	}
+	assert.Nil(db.QueryRow("select count(*) from links").Scan(&count))
+	assert.Equal(1, count)
+}
@@ -0,0 +1,7 @@
+-- Databases created before the migrations already have links, without the attribution column.
+create table if not exists links(
+	long_url    text not null,
+	short_url   text not null,
+	attribution text not null default ''
+);
+alter table links add column if not exists attribution text not null default '';
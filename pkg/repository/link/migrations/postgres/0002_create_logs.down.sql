@@ -0,0 +1 @@
+-- Databases created before the migrations already had logs, rolling the baseline back would drop their data.
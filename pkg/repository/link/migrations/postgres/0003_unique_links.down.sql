@@ -0,0 +1,2 @@
+drop index if exists links_short_url_idx;
+drop index if exists links_long_url_key;
@@ -0,0 +1,5 @@
+-- Before this migration a pair could be inserted more than once, the migration refuses to run until duplicate web URLs are removed.
+-- Deeplinks stay non-unique: every unknown web URL used to be stored with the home page deeplink, and other slugs of a product
+-- share its deeplink. Fallback conversions aren't stored anymore and a new pair isn't inserted when its deeplink is stored.
+create unique index links_long_url_key on links(long_url);
+create index links_short_url_idx on links(short_url);
@@ -0,0 +1,2 @@
+alter table links drop column source;
+alter table links drop column converter_version;
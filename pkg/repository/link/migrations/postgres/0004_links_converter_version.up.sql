@@ -0,0 +1,3 @@
+-- Rows stored before this migration have an empty converter version and source, the backfill recomputes them from the web URL.
+alter table links add column converter_version text not null default '';
+alter table links add column source text not null default '';
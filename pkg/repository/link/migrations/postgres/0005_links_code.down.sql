@@ -0,0 +1,2 @@
+drop index if exists links_code_key;
+alter table links drop column code;
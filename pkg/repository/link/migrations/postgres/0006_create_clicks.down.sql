@@ -0,0 +1,2 @@
+drop index if exists clicks_fingerprint_idx;
+drop table if exists clicks;
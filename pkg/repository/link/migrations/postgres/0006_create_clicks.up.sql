@@ -0,0 +1,9 @@
+-- Smart redirects to phones, the app claims the deeplink of its click after being installed.
+create table if not exists clicks(
+	token       text primary key,
+	fingerprint text not null,
+	deeplink    text not null,
+	created_at  timestamp not null,
+	claimed_at  timestamp
+);
+create index if not exists clicks_fingerprint_idx on clicks(fingerprint, created_at);
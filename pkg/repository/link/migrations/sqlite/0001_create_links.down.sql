@@ -0,0 +1 @@
+-- Databases created before the migrations already had links, rolling the baseline back would drop their data.
@@ -0,0 +1,5 @@
+create table if not exists links(
+	long_url    text not null,
+	short_url   text not null,
+	attribution text not null default ''
+);
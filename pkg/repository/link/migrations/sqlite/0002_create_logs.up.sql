@@ -0,0 +1,4 @@
+create table if not exists logs(
+	created_at timestamp not null,
+	info       text not null
+);
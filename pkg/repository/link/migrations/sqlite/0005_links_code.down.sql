@@ -0,0 +1,15 @@
+-- The bundled SQLite can't drop columns, links is rebuilt without it.
+drop index if exists links_code_key;
+create table links_old(
+	long_url          text not null,
+	short_url         text not null,
+	attribution       text not null default '',
+	converter_version text not null default '',
+	source            text not null default ''
+);
+insert into links_old(long_url, short_url, attribution, converter_version, source)
+	select long_url, short_url, attribution, converter_version, source from links;
+drop table links;
+alter table links_old rename to links;
+create unique index links_long_url_key on links(long_url);
+create unique index links_short_url_key on links(short_url);
@@ -0,0 +1,3 @@
+-- Short codes redirect to the stored mapping, rows stored before this migration get one from the codes command.
+alter table links add column code text;
+create unique index links_code_key on links(code);
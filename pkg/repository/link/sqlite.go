@@ -0,0 +1,40 @@
+package link
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+/*
+Opens (or creates) an SQLite database file. ':memory:' gives a database which lives as long as the *sql.DB.
+*/
+
+func OpenSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer at a time, and every connection to ':memory:' is a new database.
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+/*
+Opens an SQLite database and applies the pending migrations, a local database is always kept up to date.
+*/
+
+func NewSQLiteRepository(path string) (*Repository, error) {
+	db, err := OpenSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := NewMigrator(db, SQLite)
+	if err == nil {
+		_, err = migrator.Up()
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return NewRepository(db), nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+/*
+Attribution keeps the marketing parameters (utm_*, adjust_*, gclid) of a link. They are taken out
+before conversion, so that a product link shared by different campaigns is stored once, and put back
+on the converted link.
+*/
+
+type Attribution map[string]string
+
+/*
+Order in which attribution parameters are written, adjust_* parameters follow in alphabetical order.
+*/
+
+var attributionParamOrder = []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content", "gclid"}
+
+func isAttributionParam(key string) bool {
+	if strings.HasPrefix(key, "adjust_") {
+		return true
+	}
+	for _, k := range attributionParamOrder {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Takes the attribution parameters out of a web URL or deeplink. Keys are lowercased and values trimmed,
+utm_source and utm_medium values are lowercased too so 'Facebook' and 'facebook' count as one source.
+Parameters without value are dropped, the first value of a repeated parameter wins.
+*/
+
+func ExtractAttribution(link string) (string, Attribution) {
+	idx := strings.Index(link, "?")
+	if idx == -1 {
+		return link, Attribution{}
+	}
+	base, rawQuery := link[:idx], link[idx+1:]
+	fragment := ""
+	if f := strings.Index(rawQuery, "#"); f != -1 {
+		rawQuery, fragment = rawQuery[:f], rawQuery[f:]
+	}
+
+	attribution := Attribution{}
+	var kept []string
+	for _, piece := range queryPieces(rawQuery) {
+		key := strings.ToLower(queryPieceKey(piece))
+		if !isAttributionParam(key) {
+			kept = append(kept, piece)
+			continue
+		}
+		value, err := url.QueryUnescape(strings.TrimPrefix(piece[len(key):], "="))
+		if err != nil {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if key == "utm_source" || key == "utm_medium" {
+			value = strings.ToLower(value)
+		}
+		if _, ok := attribution[key]; !ok && value != "" {
+			attribution[key] = value
+		}
+	}
+
+	// Deeplinks keep their '?' even without parameters, e.g. 'ty://?Page=Home'.
+	if len(kept) == 0 && !strings.HasSuffix(base, "//") {
+		return base + fragment, attribution
+	}
+	return base + "?" + strings.Join(kept, "&") + fragment, attribution
+}
+
+func (a Attribution) keys() []string {
+	var keys []string
+	for _, k := range attributionParamOrder {
+		if _, ok := a[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	var adjust []string
+	for k := range a {
+		if strings.HasPrefix(k, "adjust_") {
+			adjust = append(adjust, k)
+		}
+	}
+	sort.Strings(adjust)
+	return append(keys, adjust...)
+}
+
+/*
+Encodes the parameters in a stable order, e.g. 'utm_source=newsletter&utm_campaign=summer'.
+*/
+
+func (a Attribution) Encode() string {
+	var pieces []string
+	for _, k := range a.keys() {
+		pieces = append(pieces, k+"="+url.QueryEscape(a[k]))
+	}
+	return strings.Join(pieces, "&")
+}
+
+/*
+Puts the parameters back on a converted web URL or deeplink.
+*/
+
+func (a Attribution) AppendTo(link string) string {
+	encoded := a.Encode()
+	if encoded == "" {
+		return link
+	}
+	if !strings.Contains(link, "?") {
+		return link + "?" + encoded
+	}
+	if strings.HasSuffix(link, "?") || strings.HasSuffix(link, "&") {
+		return link + encoded
+	}
+	return link + "&" + encoded
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExtractAttribution(t *testing.T) {
+	assert := assert.New(t)
+
+	links := []struct {
+		testLink            string
+		expectedLink        string
+		expectedAttribution string
+	}{
+		{"https://www.trendyol.com/casio/saat-p-1?utm_source=Facebook&boutiqueId=2&UTM_Campaign=Yaz%20Indirimi&gclid=AbC", "https://www.trendyol.com/casio/saat-p-1?boutiqueId=2", "utm_source=facebook&utm_campaign=Yaz+Indirimi&gclid=AbC"},
+		{"https://www.trendyol.com/sr?q=elbise&adjust_tracker=x1&adjust_campaign=c&utm_medium=", "https://www.trendyol.com/sr?q=elbise", "adjust_campaign=c&adjust_tracker=x1"},
+		{"https://www.trendyol.com/casio/saat-p-1?utm_source=a&utm_source=b", "https://www.trendyol.com/casio/saat-p-1", "utm_source=a"},
+		{"ty://?Page=Product&ContentId=1&utm_source=push", "ty://?Page=Product&ContentId=1", "utm_source=push"},
+		{"ty://?utm_source=push", "ty://?", "utm_source=push"},
+		{"https://www.trendyol.com/sepet", "https://www.trendyol.com/sepet", ""},
+	}
+	for _, v := range links {
+		actualLink, attribution := ExtractAttribution(v.testLink)
+		assert.Equal(v.expectedLink, actualLink)
+		assert.Equal(v.expectedAttribution, attribution.Encode())
+	}
+}
+
+func TestAttributionAppendTo(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterService(nil)
+
+	requestLink, attribution := ExtractAttribution("https://www.trendyol.com/casio/saat-p-1925865?utm_source=newsletter&merchantId=105064&utm_campaign=summer")
+	deepLink, _ := c.CreateDeepLink(requestLink)
+	assert.Equal("ty://?Page=Product&ContentId=1925865&MerchantId=105064&utm_source=newsletter&utm_campaign=summer", attribution.AppendTo(deepLink))
+
+	requestLink, attribution = ExtractAttribution("ty://?Page=Search&Query=elbise&gclid=xyz")
+	webURL, _ := c.CreateWebURL(requestLink)
+	assert.Equal("https://www.trendyol.com/sr?q=elbise&gclid=xyz", attribution.AppendTo(webURL))
+
+	assert.Equal("https://www.trendyol.com/sepet?utm_source=a", Attribution{"utm_source": "a"}.AppendTo("https://www.trendyol.com/sepet"))
+	assert.Equal("https://www.trendyol.com/sepet", Attribution{}.AppendTo("https://www.trendyol.com/sepet"))
+}
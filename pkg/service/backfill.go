@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"trendyolcase/pkg/model"
+	"trendyolcase/pkg/repository/link"
+)
+
+/*
+BackfillRepository is implemented by the repositories which can list and rewrite the stored mappings.
+*/
+
+type BackfillRepository interface {
+	StaleMappings(converterVersion string) ([]model.Mapping, error)
+	UpdateMapping(webURL string, m model.Mapping) error
+}
+
+/*
+BackfillChange is a stored mapping whose recomputed link differs from the stored one.
+*/
+
+type BackfillChange struct {
+	Stored     model.Mapping
+	Recomputed model.Link
+	Err        error
+}
+
+type BackfillReport struct {
+	ConverterVersion string
+	Checked          int
+	Unchanged        int
+	Changes          []BackfillChange
+	Failed           []BackfillChange
+}
+
+/*
+Runs the current converters over the mappings stored by another converter version. Rows converted from a deeplink
+get a new webURL, the others (also the rows stored before mappings had a source) get a new deeplink.
+Without apply nothing is written and the report lists what would change. With apply unchanged rows are tagged with
+the current version and changed rows are rewritten, rows conflicting with another stored mapping are left as they are.
+Invalidate is called for every rewritten row, e.g. to drop it from a cache.
+*/
+
+func (l *ConverterService) Backfill(r BackfillRepository, apply bool, invalidate func(old model.Link)) (BackfillReport, error) {
+	report := BackfillReport{ConverterVersion: l.ConverterVersion()}
+	stale, err := r.StaleMappings(report.ConverterVersion)
+	if err != nil {
+		return report, err
+	}
+	for _, stored := range stale {
+		report.Checked++
+		recomputed, err := l.recompute(stored)
+		change := BackfillChange{Stored: stored, Recomputed: recomputed, Err: err}
+		if err != nil {
+			report.Failed = append(report.Failed, change)
+			continue
+		}
+		if recomputed == stored.Link {
+			report.Unchanged++
+		} else {
+			report.Changes = append(report.Changes, change)
+		}
+		if !apply {
+			continue
+		}
+
+		source := stored.Source
+		if source == "" {
+			source = model.SourceWebURL
+		}
+		err = r.UpdateMapping(stored.WebUrl, model.Mapping{Link: recomputed, ConverterVersion: report.ConverterVersion, Source: source})
+		if errors.Is(err, link.ErrWrite) {
+			return report, err
+		}
+		if err != nil {
+			change.Err = err
+			report.Failed = append(report.Failed, change)
+			if recomputed != stored.Link {
+				report.Changes = report.Changes[:len(report.Changes)-1]
+			}
+			continue
+		}
+		if invalidate != nil && recomputed != stored.Link {
+			invalidate(stored.Link)
+		}
+	}
+	return report, nil
+}
+
+func (l *ConverterService) recompute(stored model.Mapping) (model.Link, error) {
+	if stored.Source == model.SourceDeepLink {
+		webURL, err := l.CreateWebURL(stored.Deeplink)
+		return model.Link{WebUrl: webURL, Deeplink: stored.Deeplink}, err
+	}
+	deepLink, err := l.CreateDeepLink(stored.WebUrl)
+	return model.Link{WebUrl: stored.WebUrl, Deeplink: deepLink}, err
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"trendyolcase/pkg/model"
+	"trendyolcase/pkg/repository/link"
+)
+
+func TestBackfill(t *testing.T) {
+	assert := assert.New(t)
+	repository := link.NewMemoryRepository()
+	c := NewConverterService(repository)
+
+	stored := []model.Mapping{
+		// Stored before mappings had a version, with a deeplink an older converter got wrong.
+		{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=saat", Deeplink: "ty://?Page=Home"}},
+		{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=etek", Deeplink: "ty://?Page=Search&Query=etek"}, ConverterVersion: "0/1"},
+		{Link: model.Link{WebUrl: "https://www.trendyol.com/old-x-b103", Deeplink: "ty://?Page=Brand&BrandId=103"}, ConverterVersion: "0/1", Source: model.SourceDeepLink},
+		{Link: model.Link{WebUrl: "https://www.trendyol.com/casio/saat-p-1", Deeplink: "ty://?Page=Product&ContentId=1"}, ConverterVersion: c.ConverterVersion()},
+	}
+	for _, m := range stored {
+		_, err := repository.GetOrCreate(m)
+		assert.Nil(err)
+	}
+
+	report, err := c.Backfill(repository, false, nil)
+	assert.Nil(err)
+	assert.Equal(3, report.Checked)
+	assert.Equal(1, report.Unchanged)
+	assert.Equal(2, len(report.Changes))
+	deepLink, _ := repository.GetDeepLinkIfWebURLExist("https://www.trendyol.com/sr?q=saat")
+	assert.Equal("ty://?Page=Home", deepLink)
+
+	var invalidated []model.Link
+	report, err = c.Backfill(repository, true, func(old model.Link) { invalidated = append(invalidated, old) })
+	assert.Nil(err)
+	assert.Equal(2, len(report.Changes))
+	assert.Equal(2, len(invalidated))
+	deepLink, _ = repository.GetDeepLinkIfWebURLExist("https://www.trendyol.com/sr?q=saat")
+	assert.Equal("ty://?Page=Search&Query=saat", deepLink)
+	webURL, _ := repository.GetWebURLIfDeepLinkExist("ty://?Page=Brand&BrandId=103")
+	assert.Equal("https://www.trendyol.com/brand-x-b103", webURL)
+
+	report, err = c.Backfill(repository, true, nil)
+	assert.Nil(err)
+	assert.Equal(0, report.Checked)
+}
+
+func TestBackfillConflict(t *testing.T) {
+	assert := assert.New(t)
+	repository := link.NewMemoryRepository()
+	c := NewConverterService(repository)
+
+	_, err := repository.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=saat", Deeplink: "ty://?Page=Search&Query=saat"}, ConverterVersion: c.ConverterVersion()})
+	assert.Nil(err)
+	_, err = repository.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/brand-x-b103", Deeplink: "ty://?Page=Search&Query=saat&x=1"}, ConverterVersion: "0/1", Source: model.SourceDeepLink})
+	assert.Nil(err)
+
+	// Unknown parameters are dropped now, the recomputed webURL of the second row belongs to the first one.
+	report, err := c.Backfill(repository, true, nil)
+	assert.Nil(err)
+	assert.Equal(1, report.Checked)
+	assert.Equal(0, len(report.Changes))
+	assert.Equal(1, len(report.Failed))
+	assert.True(errors.Is(report.Failed[0].Err, link.ErrConflict))
+}
@@ -0,0 +1,71 @@
+package service
+
+/*
+BatchResult is the conversion of one batch input. Output has the attribution parameters of the input put back.
+Fallback, ReasonCode and Reason tell whether Output is the fallback page and why, like ConversionResult.
+*/
+
+type BatchResult struct {
+	Input      string
+	Output     string
+	Created    bool
+	Fallback   bool
+	ReasonCode FallbackReason
+	Reason     string
+	Err        error
+}
+
+/*
+Converts web URLs to deeplinks like GetOrCreateDeepLink does, looking up the stored ones with a single repository call.
+Every input is converted, so that fallback conversions are neither stored nor looked up.
+Results are in the order of the inputs, an input that can't be converted gets its own error. The returned error
+is for the lookup, when it fails nothing is converted.
+*/
+
+func (l *ConverterService) BatchDeepLinks(webURLs []string) ([]BatchResult, error) {
+	return l.batch(webURLs, l.ConverterRepository.GetDeepLinks, func(webURL string, attribution Attribution, stored string) (ConversionResult, bool, error) {
+		_, result, created, err := l.resolveDeepLink(webURL, attribution, stored)
+		return result, created, err
+	})
+}
+
+/*
+Same as BatchDeepLinks in the other direction.
+*/
+
+func (l *ConverterService) BatchWebURLs(deepLinks []string) ([]BatchResult, error) {
+	return l.batch(deepLinks, l.ConverterRepository.GetWebURLs, func(deepLink string, attribution Attribution, stored string) (ConversionResult, bool, error) {
+		_, result, created, err := l.resolveWebURL(deepLink, attribution, stored)
+		return result, created, err
+	})
+}
+
+func (l *ConverterService) batch(inputs []string, lookup func([]string) (map[string]string, error),
+	resolve func(string, Attribution, string) (ConversionResult, bool, error)) ([]BatchResult, error) {
+	requestLinks := make([]string, len(inputs))
+	attributions := make([]Attribution, len(inputs))
+	for i, input := range inputs {
+		requestLinks[i], attributions[i] = ExtractAttribution(input)
+	}
+	stored, err := lookup(requestLinks)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, len(inputs))
+	for i, input := range inputs {
+		result := BatchResult{Input: input}
+		var conversion ConversionResult
+		conversion, result.Created, result.Err = resolve(requestLinks[i], attributions[i], stored[requestLinks[i]])
+		if result.Created {
+			// Repeated inputs of the batch get the stored output.
+			stored[requestLinks[i]] = conversion.Output
+		}
+		if result.Err == nil {
+			result.Output = attributions[i].AppendTo(conversion.Output)
+			result.Fallback, result.ReasonCode, result.Reason = conversion.Fallback, conversion.ReasonCode, conversion.Reason
+		}
+		results[i] = result
+	}
+	return results, nil
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"trendyolcase/pkg/repository/link"
+)
+
+func TestBatchDeepLinks(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterService(link.NewMemoryRepository())
+	_, _, err := c.GetOrCreateDeepLink("https://www.trendyol.com/sr?q=saat", Attribution{})
+	assert.Nil(err)
+
+	results, err := c.BatchDeepLinks([]string{
+		"https://www.trendyol.com/sr?q=saat&utm_source=feed",
+		"not a url",
+		"https://www.trendyol.com/casio/saat-p-1925865",
+		"https://www.trendyol.com/casio/saat-p-1925865",
+	})
+	assert.Nil(err)
+	assert.Equal(4, len(results))
+
+	assert.Equal("ty://?Page=Search&Query=saat&utm_source=feed", results[0].Output)
+	assert.False(results[0].Created)
+	assert.NotNil(results[1].Err)
+	assert.Equal("", results[1].Output)
+	assert.Equal("ty://?Page=Product&ContentId=1925865", results[2].Output)
+	assert.True(results[2].Created)
+	assert.Equal("ty://?Page=Product&ContentId=1925865", results[3].Output)
+	assert.False(results[3].Created)
+
+	results, err = c.BatchWebURLs([]string{"ty://?Page=Product&ContentId=1925865", "ty://?Page=Basket"})
+	assert.Nil(err)
+	assert.Equal("https://www.trendyol.com/casio/saat-p-1925865", results[0].Output)
+	assert.Equal("https://www.trendyol.com/sepet", results[1].Output)
+	assert.True(results[1].Created)
+}
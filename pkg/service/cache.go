@@ -0,0 +1,246 @@
+package service
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+	"trendyolcase/pkg/model"
+)
+
+/*
+CachedLinkRepository keeps the most recently used mappings of a LinkRepository in memory, in both directions.
+Only stored mappings are cached, a lookup that finds nothing always goes to the repository.
+Entries expire after the TTL and the least recently used one is evicted when the cache is full.
+Short codes and clicks aren't cached, their methods go to the repository.
+*/
+
+type CachedLinkRepository struct {
+	LinkRepository
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	order   *list.List
+
+	hits   uint64
+	misses uint64
+}
+
+type cacheKey struct {
+	toDeepLink bool
+	link       string
+}
+
+type cacheEntry struct {
+	key       cacheKey
+	value     string
+	expiresAt time.Time
+}
+
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Size   int
+}
+
+/*
+size is the number of cached lookups (each direction counts separately), ttl 0 keeps entries until they are evicted.
+*/
+
+func NewCachedLinkRepository(r LinkRepository, size int, ttl time.Duration) *CachedLinkRepository {
+	return &CachedLinkRepository{
+		LinkRepository: r,
+		size:           size,
+		ttl:            ttl,
+		entries:        map[cacheKey]*list.Element{},
+		order:          list.New(),
+	}
+}
+
+func (c *CachedLinkRepository) GetDeepLinkIfWebURLExist(webURL string) (string, error) {
+	if deepLink, ok := c.get(cacheKey{toDeepLink: true, link: webURL}); ok {
+		return deepLink, nil
+	}
+	deepLink, err := c.LinkRepository.GetDeepLinkIfWebURLExist(webURL)
+	if err == nil && deepLink != "" {
+		c.put(cacheKey{toDeepLink: true, link: webURL}, deepLink)
+	}
+	return deepLink, err
+}
+
+func (c *CachedLinkRepository) GetWebURLIfDeepLinkExist(deepLink string) (string, error) {
+	if webURL, ok := c.get(cacheKey{toDeepLink: false, link: deepLink}); ok {
+		return webURL, nil
+	}
+	webURL, err := c.LinkRepository.GetWebURLIfDeepLinkExist(deepLink)
+	if err == nil && webURL != "" {
+		c.put(cacheKey{toDeepLink: false, link: deepLink}, webURL)
+	}
+	return webURL, err
+}
+
+/*
+Cached lookups are answered from the cache, the others are fetched from the repository with one call.
+*/
+
+func (c *CachedLinkRepository) GetDeepLinks(webURLs []string) (map[string]string, error) {
+	return c.getMany(webURLs, true, c.LinkRepository.GetDeepLinks)
+}
+
+func (c *CachedLinkRepository) GetWebURLs(deepLinks []string) (map[string]string, error) {
+	return c.getMany(deepLinks, false, c.LinkRepository.GetWebURLs)
+}
+
+func (c *CachedLinkRepository) getMany(links []string, toDeepLink bool, fetch func([]string) (map[string]string, error)) (map[string]string, error) {
+	found := map[string]string{}
+	var missing []string
+	for _, link := range links {
+		if value, ok := c.get(cacheKey{toDeepLink: toDeepLink, link: link}); ok {
+			found[link] = value
+		} else {
+			missing = append(missing, link)
+		}
+	}
+	if len(missing) == 0 {
+		return found, nil
+	}
+	fetched, err := fetch(missing)
+	if err != nil {
+		return nil, err
+	}
+	for link, value := range fetched {
+		c.put(cacheKey{toDeepLink: toDeepLink, link: link}, value)
+		found[link] = value
+	}
+	return found, nil
+}
+
+/*
+The requested links are dropped from the cache and the stored pair returned by the repository is cached in their place,
+so a rewritten mapping is never served from the cache.
+*/
+
+func (c *CachedLinkRepository) GetOrCreate(m model.Mapping) (model.Link, error) {
+	c.Invalidate(m.WebUrl, m.Deeplink)
+	stored, err := c.LinkRepository.GetOrCreate(m)
+	if stored.WebUrl != "" && stored.Deeplink != "" {
+		c.put(cacheKey{toDeepLink: true, link: stored.WebUrl}, stored.Deeplink)
+		c.put(cacheKey{toDeepLink: false, link: stored.Deeplink}, stored.WebUrl)
+	}
+	return stored, err
+}
+
+/*
+Lists the stale mappings of the repository, so that the backfill can run through the cache.
+*/
+
+func (c *CachedLinkRepository) StaleMappings(converterVersion string) ([]model.Mapping, error) {
+	r, ok := c.LinkRepository.(BackfillRepository)
+	if !ok {
+		return nil, errors.New("Repository can't list stale mappings.")
+	}
+	return r.StaleMappings(converterVersion)
+}
+
+/*
+Rewrites the mapping in the repository and drops the lookups of both the old and the new webURL and deeplink,
+so a backfill through this repository serves the rewritten mapping right away. A backfill of another process can't
+reach the cache, the service then purges it on SIGHUP. The lookups are dropped again after the write, a lookup running
+concurrently may have cached the old pair in between.
+*/
+
+func (c *CachedLinkRepository) UpdateMapping(webURL string, m model.Mapping) error {
+	r, ok := c.LinkRepository.(BackfillRepository)
+	if !ok {
+		return errors.New("Repository can't rewrite mappings.")
+	}
+	oldDeepLink, _ := c.LinkRepository.GetDeepLinkIfWebURLExist(webURL)
+	invalidate := func() {
+		c.Invalidate(webURL, oldDeepLink)
+		c.Invalidate(m.WebUrl, m.Deeplink)
+	}
+	invalidate()
+	err := r.UpdateMapping(webURL, m)
+	invalidate()
+	return err
+}
+
+/*
+Drops the cached lookups of a webURL and a deeplink, either one can be empty.
+*/
+
+func (c *CachedLinkRepository) Invalidate(webURL string, deepLink string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if webURL != "" {
+		c.remove(cacheKey{toDeepLink: true, link: webURL})
+	}
+	if deepLink != "" {
+		c.remove(cacheKey{toDeepLink: false, link: deepLink})
+	}
+}
+
+/*
+Drops every cached lookup, e.g. after the stored mappings are recomputed.
+*/
+
+func (c *CachedLinkRepository) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[cacheKey]*list.Element{}
+	c.order.Init()
+}
+
+func (c *CachedLinkRepository) Stats() CacheStats {
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+	return CacheStats{Hits: atomic.LoadUint64(&c.hits), Misses: atomic.LoadUint64(&c.misses), Size: size}
+}
+
+func (c *CachedLinkRepository) get(key cacheKey) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return "", false
+	}
+	entry := element.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.expiresAt) {
+		c.remove(key)
+		atomic.AddUint64(&c.misses, 1)
+		return "", false
+	}
+	c.order.MoveToFront(element)
+	atomic.AddUint64(&c.hits, 1)
+	return entry.value, true
+}
+
+func (c *CachedLinkRepository) put(key cacheKey, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size <= 0 {
+		return
+	}
+	entry := &cacheEntry{key: key, value: value, expiresAt: time.Now().Add(c.ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back().Value.(*cacheEntry).key)
+	}
+}
+
+func (c *CachedLinkRepository) remove(key cacheKey) {
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+}
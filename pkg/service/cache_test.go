@@ -0,0 +1,85 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"trendyolcase/pkg/model"
+	"trendyolcase/pkg/repository/link"
+)
+
+func TestCachedLinkRepository(t *testing.T) {
+	assert := assert.New(t)
+	c := NewCachedLinkRepository(link.NewMemoryRepository(), 2, time.Minute)
+
+	_, err := c.GetDeepLinkIfWebURLExist("https://www.trendyol.com/sr?q=saat")
+	assert.NotNil(err)
+	_, err = c.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=saat", Deeplink: "ty://?Page=Search&Query=saat"}})
+	assert.Nil(err)
+
+	deepLink, err := c.GetDeepLinkIfWebURLExist("https://www.trendyol.com/sr?q=saat")
+	assert.Nil(err)
+	assert.Equal("ty://?Page=Search&Query=saat", deepLink)
+	webURL, err := c.GetWebURLIfDeepLinkExist("ty://?Page=Search&Query=saat")
+	assert.Nil(err)
+	assert.Equal("https://www.trendyol.com/sr?q=saat", webURL)
+	assert.Equal(CacheStats{Hits: 2, Misses: 1, Size: 2}, c.Stats())
+
+	// The least recently used lookup is evicted.
+	_, err = c.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=etek", Deeplink: "ty://?Page=Search&Query=etek"}})
+	assert.Nil(err)
+	assert.Equal(2, c.Stats().Size)
+	_, err = c.GetDeepLinkIfWebURLExist("https://www.trendyol.com/sr?q=saat")
+	assert.Nil(err)
+	assert.Equal(uint64(2), c.Stats().Misses)
+
+	c.Invalidate("https://www.trendyol.com/sr?q=saat", "")
+	_, err = c.GetDeepLinkIfWebURLExist("https://www.trendyol.com/sr?q=saat")
+	assert.Nil(err)
+	assert.Equal(uint64(3), c.Stats().Misses)
+
+	c.Purge()
+	assert.Equal(0, c.Stats().Size)
+}
+
+func TestCachedLinkRepositoryTTL(t *testing.T) {
+	assert := assert.New(t)
+	c := NewCachedLinkRepository(link.NewMemoryRepository(), 10, time.Millisecond)
+	_, err := c.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=saat", Deeplink: "ty://?Page=Search&Query=saat"}})
+	assert.Nil(err)
+
+	time.Sleep(5 * time.Millisecond)
+	deepLink, err := c.GetDeepLinkIfWebURLExist("https://www.trendyol.com/sr?q=saat")
+	assert.Nil(err)
+	assert.Equal("ty://?Page=Search&Query=saat", deepLink)
+	assert.Equal(CacheStats{Hits: 0, Misses: 1, Size: 2}, c.Stats())
+}
+
+func TestCachedLinkRepositoryUpdateMapping(t *testing.T) {
+	assert := assert.New(t)
+	c := NewCachedLinkRepository(link.NewMemoryRepository(), 10, time.Minute)
+	saat := model.Link{WebUrl: "https://www.trendyol.com/sr?q=saat", Deeplink: "ty://?Page=Search&Query=saat"}
+	_, err := c.GetOrCreate(model.Mapping{Link: saat})
+	assert.Nil(err)
+	deepLink, err := c.GetDeepLinkIfWebURLExist(saat.WebUrl)
+	assert.Nil(err)
+	assert.Equal(saat.Deeplink, deepLink)
+
+	// The rewrite is served right away, the old deeplink isn't found anymore.
+	rewritten := model.Link{WebUrl: saat.WebUrl, Deeplink: "ty://?Page=Search&Query=saat&sst=PRICE_BY_ASC"}
+	assert.Nil(c.UpdateMapping(saat.WebUrl, model.Mapping{Link: rewritten}))
+	deepLink, err = c.GetDeepLinkIfWebURLExist(saat.WebUrl)
+	assert.Nil(err)
+	assert.Equal(rewritten.Deeplink, deepLink)
+	webURL, err := c.GetWebURLIfDeepLinkExist(saat.Deeplink)
+	assert.Nil(err)
+	assert.Equal("", webURL)
+	webURL, err = c.GetWebURLIfDeepLinkExist(rewritten.Deeplink)
+	assert.Nil(err)
+	assert.Equal(saat.WebUrl, webURL)
+
+	var backfill BackfillRepository = c
+	stale, err := backfill.StaleMappings("1/1")
+	assert.Nil(err)
+	assert.Equal(1, len(stale))
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Product keeps the slugs used in the product page URL, 'https://www.trendyol.com/{brand}/{name}-p-{contentId}'.
+*/
+
+type Product struct {
+	ContentID string `json:"contentId"`
+	Brand     string `json:"brand"`
+	Name      string `json:"name"`
+}
+
+/*
+ProductCatalog is consulted while converting product deeplinks to URLs to fill in the real
+brand and name slugs of a content id.
+*/
+
+type ProductCatalog interface {
+	GetProduct(contentID string) (Product, bool)
+}
+
+type FileProductCatalog struct {
+	products map[string]Product
+}
+
+/*
+Loads the catalog from a local file. '.json' files hold an array of products,
+'.csv' files have a 'contentId,brand,name' header row followed by one product per line.
+*/
+
+func NewFileProductCatalog(path string) (*FileProductCatalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var products []Product
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.NewDecoder(f).Decode(&products)
+	case ".csv":
+		products, err = readCatalogCSV(f)
+	default:
+		err = errors.New("Product catalog should be a .json or .csv file.")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &FileProductCatalog{products: make(map[string]Product, len(products))}
+	for _, p := range products {
+		if p.ContentID == "" || p.Brand == "" || p.Name == "" {
+			return nil, errors.New("Product catalog has a product without contentId, brand or name.")
+		}
+		c.products[p.ContentID] = p
+	}
+	return c, nil
+}
+
+func (c *FileProductCatalog) GetProduct(contentID string) (Product, bool) {
+	p, ok := c.products[contentID]
+	return p, ok
+}
+
+func readCatalogCSV(r io.Reader) ([]Product, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.TrimSpace(name)] = i
+	}
+	contentIDColumn, ok1 := columns["contentId"]
+	brandColumn, ok2 := columns["brand"]
+	nameColumn, ok3 := columns["name"]
+	if !(ok1 && ok2 && ok3) {
+		return nil, errors.New("Product catalog CSV header should contain contentId, brand and name.")
+	}
+
+	var products []Product
+	for _, record := range records[1:] {
+		products = append(products, Product{
+			ContentID: record[contentIDColumn],
+			Brand:     record[brandColumn],
+			Name:      record[nameColumn],
+		})
+	}
+	return products, nil
+}
+
+/*
+Replaces the 'brand/name' placeholder of a converted product page URL with the real slugs.
+If there is no catalog or the product is unknown, the URL is returned as is.
+*/
+
+func (l *ConverterService) withProductSlug(rules *RuleSet, webURL string) string {
+	placeholderProductURL := rules.productPlaceholderURL()
+
+	if l.Catalog == nil || !strings.HasPrefix(webURL, placeholderProductURL) {
+		return webURL
+	}
+	rest := strings.TrimPrefix(webURL, placeholderProductURL)
+	contentID := rest
+	if idx := strings.Index(rest, "?"); idx != -1 {
+		contentID = rest[:idx]
+	}
+
+	product, ok := l.Catalog.GetProduct(contentID)
+	if !ok {
+		return webURL
+	}
+	return rules.WebBaseURL + "/" + url.PathEscape(product.Brand) + "/" + url.PathEscape(product.Name) + rules.Product.PathSeparator + rest
+}
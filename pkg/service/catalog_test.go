@@ -0,0 +1,61 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileProductCatalog(t *testing.T) {
+	assert := assert.New(t)
+
+	jsonPath := writeTestFile(t, "catalog.json", `[{"contentId":"1925865","brand":"casio","name":"erkek-kol-saati"}]`)
+	csvPath := writeTestFile(t, "catalog.csv", "contentId,brand,name\n1925865,casio,erkek-kol-saati\n")
+
+	for _, path := range []string{jsonPath, csvPath} {
+		catalog, err := NewFileProductCatalog(path)
+		assert.Nil(err)
+		product, ok := catalog.GetProduct("1925865")
+		assert.True(ok)
+		assert.Equal(Product{ContentID: "1925865", Brand: "casio", Name: "erkek-kol-saati"}, product)
+		_, ok = catalog.GetProduct("1")
+		assert.False(ok)
+	}
+
+	_, err := NewFileProductCatalog(writeTestFile(t, "catalog.txt", ""))
+	assert.NotNil(err)
+	_, err = NewFileProductCatalog(writeTestFile(t, "bad.csv", "id,brand\n1,casio\n"))
+	assert.NotNil(err)
+}
+
+func TestCreateWebURLWithProductCatalog(t *testing.T) {
+	assert := assert.New(t)
+
+	catalog, err := NewFileProductCatalog(writeTestFile(t, "catalog.csv", "contentId,brand,name\n1925865,casio,erkek-kol-saati\n"))
+	assert.Nil(err)
+	c := NewConverterService(nil)
+	c.Catalog = catalog
+
+	deepLinks := []struct {
+		testDeepLink string
+		expectedURL  string
+	}{
+		{"ty://?Page=Product&ContentId=1925865", "https://www.trendyol.com/casio/erkek-kol-saati-p-1925865"},
+		{"ty://?Page=Product&ContentId=1925865&MerchantId=105064", "https://www.trendyol.com/casio/erkek-kol-saati-p-1925865?merchantId=105064"},
+		{"ty://?Page=Product&ContentId=12", "https://www.trendyol.com/brand/name-p-12"},
+		{"ty://?Page=Search&Query=elbise", "https://www.trendyol.com/sr?q=elbise"},
+	}
+	for _, v := range deepLinks {
+		actualWebURL, _ := c.CreateWebURL(v.testDeepLink)
+		assert.Equal(v.expectedURL, actualWebURL, "Should be %s", v.expectedURL)
+	}
+}
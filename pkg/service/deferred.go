@@ -0,0 +1,93 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"time"
+	"trendyolcase/pkg/model"
+	"trendyolcase/pkg/repository/link"
+)
+
+const (
+	DefaultDeferredWindow = time.Hour
+	// 62^22 tokens, they can't be guessed.
+	clickTokenLength = 22
+	// Install referrer parameter of the click token, the app reads it through the Play Install Referrer API.
+	ClickTokenReferrerParam = "click_token"
+)
+
+/*
+Identifies a phone without the app by its IP address and platform, the only things the browser that clicked
+and the freshly installed app have in common. The IP address isn't stored, only the hash.
+*/
+
+func ClickFingerprint(ip string, platform Platform) string {
+	sum := sha256.Sum256([]byte(string(platform) + "|" + ip))
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+Records that a phone was sent to the deeplink, so that the app can claim it if it has to be installed first.
+Returns the click token.
+*/
+
+func (l *ConverterService) RecordClick(deepLink string, fingerprint string) (string, error) {
+	token, err := randomBase62(clickTokenLength)
+	if err != nil {
+		return "", err
+	}
+	click := model.Click{Token: token, Fingerprint: fingerprint, Deeplink: deepLink, CreatedAt: time.Now().UTC()}
+	if err := l.ConverterRepository.RecordClick(click); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+/*
+Returns the deeplink of the click the installed app came from and claims it, a click is claimed once.
+The click of the token is looked up when the app has one, the latest click of the fingerprint when it has no
+token or the token is not found.
+Only clicks of the last DeferredWindow can be claimed, link.ErrNotFound is returned when there is none.
+*/
+
+func (l *ConverterService) ClaimDeferredDeepLink(token string, fingerprint string) (string, error) {
+	window := l.DeferredWindow
+	if window <= 0 {
+		window = DefaultDeferredWindow
+	}
+	since := time.Now().UTC().Add(-window)
+
+	var click model.Click
+	var err error
+	if token == "" && fingerprint == "" {
+		return "", errors.New("A click token or fingerprint is needed.")
+	}
+	err = link.ErrNotFound
+	if token != "" {
+		click, err = l.ConverterRepository.ClaimClickByToken(token, since)
+	}
+	if errors.Is(err, link.ErrNotFound) && fingerprint != "" {
+		click, err = l.ConverterRepository.ClaimClickByFingerprint(fingerprint, since)
+	}
+	if err != nil {
+		return "", err
+	}
+	return click.Deeplink, nil
+}
+
+/*
+Play Store page of the rules' Android app whose install referrer carries the click token, so the app installed
+from it claims its click by token. The App Store passes nothing to the app, iOS claims by fingerprint only.
+Returns "" when the rules have no android.package.
+*/
+
+func (l *ConverterService) PlayStoreURL(clickToken string) string {
+	pkg := l.Rules().Android.Package
+	if pkg == "" {
+		return ""
+	}
+	referrer := url.Values{ClickTokenReferrerParam: {clickToken}}.Encode()
+	return "https://play.google.com/store/apps/details?" + url.Values{"id": {pkg}, "referrer": {referrer}}.Encode()
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"trendyolcase/pkg/model"
+	"trendyolcase/pkg/repository/link"
+)
+
+func TestClaimDeferredDeepLink(t *testing.T) {
+	assert := assert.New(t)
+	repository := link.NewMemoryRepository()
+	c := ConverterService{ConverterRepository: repository, ActiveRules: NewActiveRuleSet(DefaultRuleSet())}
+	iphone := ClickFingerprint("203.0.113.7", PlatformIOS)
+	assert.NotEqual(iphone, ClickFingerprint("203.0.113.7", PlatformAndroid))
+	assert.NotContains(iphone, "203.0.113.7")
+
+	token, err := c.RecordClick("ty://?Page=Search&Query=elbise", iphone)
+	assert.Nil(err)
+	assert.Equal(clickTokenLength, len(token))
+	_, err = c.RecordClick("ty://?Page=Search&Query=etek", iphone)
+	assert.Nil(err)
+
+	deepLink, err := c.ClaimDeferredDeepLink(token, "")
+	assert.Nil(err)
+	assert.Equal("ty://?Page=Search&Query=elbise", deepLink)
+	_, err = c.ClaimDeferredDeepLink(token, "")
+	assert.True(errors.Is(err, link.ErrNotFound))
+	// A token which is not found falls back to the fingerprint.
+	deepLink, err = c.ClaimDeferredDeepLink(token, iphone)
+	assert.Nil(err)
+	assert.Equal("ty://?Page=Search&Query=etek", deepLink)
+	_, err = c.ClaimDeferredDeepLink("", iphone)
+	assert.True(errors.Is(err, link.ErrNotFound))
+	_, err = c.RecordClick("ty://?Page=Search&Query=ceket", iphone)
+	assert.Nil(err)
+	deepLink, err = c.ClaimDeferredDeepLink("", iphone)
+	assert.Nil(err)
+	assert.Equal("ty://?Page=Search&Query=ceket", deepLink)
+	_, err = c.ClaimDeferredDeepLink("", "")
+	assert.NotNil(err)
+
+	// Clicks older than the window can't be claimed.
+	c.DeferredWindow = time.Minute
+	assert.Nil(repository.RecordClick(model.Click{Token: "old", Fingerprint: iphone, Deeplink: "ty://?Page=Home", CreatedAt: time.Now().Add(-2 * time.Minute)}))
+	_, err = c.ClaimDeferredDeepLink("old", "")
+	assert.True(errors.Is(err, link.ErrNotFound))
+	_, err = c.ClaimDeferredDeepLink("", iphone)
+	assert.True(errors.Is(err, link.ErrNotFound))
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"trendyolcase/pkg/model"
+)
+
+/*
+DiffInput is one link of a corpus, a web URL converted to a deeplink or a deeplink converted to a web URL.
+Stored is the conversion served so far, e.g. the other column of a links export or a baseline written
+before a code change. When it is set it is the old side of the diff instead of the old rules' conversion.
+*/
+
+type DiffInput struct {
+	Link       string `json:"link"`
+	ToDeepLink bool   `json:"toDeepLink"`
+	Stored     string `json:"stored,omitempty"`
+}
+
+type DiffChange struct {
+	DiffInput
+	OldPageType string `json:"oldPageType"`
+	NewPageType string `json:"newPageType"`
+	Old         string `json:"old"`
+	New         string `json:"new"`
+}
+
+/*
+DiffGroup keeps the changed links of one page type, the page type the old rules gave the link.
+*/
+
+type DiffGroup struct {
+	PageType string       `json:"pageType"`
+	Changes  []DiffChange `json:"changes"`
+}
+
+type DiffReport struct {
+	OldVersion string      `json:"oldVersion"`
+	NewVersion string      `json:"newVersion"`
+	Checked    int         `json:"checked"`
+	Changed    int         `json:"changed"`
+	Groups     []DiffGroup `json:"groups"`
+}
+
+/*
+Page type of links no rule matches.
+*/
+
+const FallbackPageType = "fallback"
+
+/*
+Converts every input with both rule sets and reports the ones whose output changed. Inputs with a stored conversion
+are compared with it, so converter code changes show up too. Nothing is stored, attribution parameters are left out
+the same way the API does.
+*/
+
+func DiffRuleSets(oldRules *RuleSet, newRules *RuleSet, catalog ProductCatalog, inputs []DiffInput) DiffReport {
+	oldService := ConverterService{ActiveRules: NewActiveRuleSet(oldRules), Catalog: catalog}
+	newService := ConverterService{ActiveRules: NewActiveRuleSet(newRules), Catalog: catalog}
+	report := DiffReport{OldVersion: oldRules.Version, NewVersion: newRules.Version}
+	groups := map[string][]DiffChange{}
+	for _, input := range inputs {
+		report.Checked++
+		link, _ := ExtractAttribution(input.Link)
+		change := DiffChange{DiffInput: input}
+		change.OldPageType, change.Old = oldService.diffConvert(link, input.ToDeepLink)
+		if input.Stored != "" {
+			change.Old = input.Stored
+		}
+		change.NewPageType, change.New = newService.diffConvert(link, input.ToDeepLink)
+		if change.Old == change.New {
+			continue
+		}
+		report.Changed++
+		groups[change.OldPageType] = append(groups[change.OldPageType], change)
+	}
+
+	for pageType, changes := range groups {
+		report.Groups = append(report.Groups, DiffGroup{PageType: pageType, Changes: changes})
+	}
+	sort.Slice(report.Groups, func(i, j int) bool { return report.Groups[i].PageType < report.Groups[j].PageType })
+	return report
+}
+
+func (l *ConverterService) diffConvert(link string, toDeepLink bool) (pageType string, converted string) {
+	var err error
+	if toDeepLink {
+		converted, pageType, err = l.convertToDeepLink(link, nil)
+	} else {
+		converted, pageType, err = l.convertToWebURL(link, nil)
+	}
+	if err != nil {
+		return FallbackPageType, "error: " + err.Error()
+	}
+	return pageType, converted
+}
+
+/*
+Reads a corpus. A file starting with a CSV header that has 'long_url' and/or 'short_url' columns is an export of
+the links table, see readLinksExport. Otherwise every non-empty line is a link, 'http://' and 'https://' links are
+web URLs and the others deeplinks.
+*/
+
+func ReadDiffCorpus(r io.Reader) ([]DiffInput, error) {
+	br := bufio.NewReader(r)
+	firstLine, err := br.Peek(256)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	header := strings.SplitN(string(firstLine), "\n", 2)[0]
+	if strings.Contains(header, "long_url") || strings.Contains(header, "short_url") {
+		return readLinksExport(br)
+	}
+
+	var inputs []DiffInput
+	scanner := bufio.NewScanner(br)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		toDeepLink := strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://")
+		inputs = append(inputs, DiffInput{Link: line, ToDeepLink: toDeepLink})
+	}
+	return inputs, scanner.Err()
+}
+
+/*
+With both columns a row is converted from the side it was stored from ('source' column, the web URL when there is none)
+and compared with the stored other side. With one column its values are converted with both rule sets.
+*/
+
+func readLinksExport(r io.Reader) ([]DiffInput, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	longURL, shortURL, source := -1, -1, -1
+	for i, column := range records[0] {
+		switch strings.TrimSpace(column) {
+		case "long_url":
+			longURL = i
+		case "short_url":
+			shortURL = i
+		case "source":
+			source = i
+		}
+	}
+	if longURL == -1 && shortURL == -1 {
+		return nil, errors.New("Links export should have a long_url or short_url column.")
+	}
+
+	var inputs []DiffInput
+	for line, record := range records[1:] {
+		value := func(index int) (string, error) {
+			if index == -1 {
+				return "", nil
+			}
+			if index >= len(record) {
+				return "", fmt.Errorf("Line %d of the links export has %d columns.", line+2, len(record))
+			}
+			return strings.TrimSpace(record[index]), nil
+		}
+		webURL, err := value(longURL)
+		if err != nil {
+			return nil, err
+		}
+		deepLink, err := value(shortURL)
+		if err != nil {
+			return nil, err
+		}
+		from, err := value(source)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case webURL != "" && deepLink != "" && from == model.SourceDeepLink:
+			inputs = append(inputs, DiffInput{Link: deepLink, ToDeepLink: false, Stored: webURL})
+		case webURL != "" && deepLink != "":
+			inputs = append(inputs, DiffInput{Link: webURL, ToDeepLink: true, Stored: deepLink})
+		case webURL != "":
+			inputs = append(inputs, DiffInput{Link: webURL, ToDeepLink: true})
+		case deepLink != "":
+			inputs = append(inputs, DiffInput{Link: deepLink, ToDeepLink: false})
+		}
+	}
+	return inputs, nil
+}
+
+/*
+Writes the conversions of the inputs as a links export (long_url, short_url, source). Written before a converter
+code change and read back as the corpus after it, the diff shows what the change does. Inputs which can't be
+converted are written with their error, so a change of the error shows up too.
+*/
+
+func WriteDiffBaseline(w io.Writer, rules *RuleSet, catalog ProductCatalog, inputs []DiffInput) error {
+	service := ConverterService{ActiveRules: NewActiveRuleSet(rules), Catalog: catalog}
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"long_url", "short_url", "source"}); err != nil {
+		return err
+	}
+	for _, input := range inputs {
+		link, _ := ExtractAttribution(input.Link)
+		_, converted := service.diffConvert(link, input.ToDeepLink)
+		record := []string{link, converted, model.SourceWebURL}
+		if !input.ToDeepLink {
+			record = []string{converted, link, model.SourceDeepLink}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestReadDiffCorpus(t *testing.T) {
+	assert := assert.New(t)
+
+	inputs, err := ReadDiffCorpus(strings.NewReader("# exported from the logs\nhttps://www.trendyol.com/sr?q=saat\n\nty://?Page=Basket\n"))
+	assert.Nil(err)
+	assert.Equal([]DiffInput{
+		{Link: "https://www.trendyol.com/sr?q=saat", ToDeepLink: true},
+		{Link: "ty://?Page=Basket", ToDeepLink: false},
+	}, inputs)
+
+	// Rows of a links export are converted from their source and compared with the stored other side.
+	inputs, err = ReadDiffCorpus(strings.NewReader("long_url,short_url,attribution,source\n" +
+		"https://www.trendyol.com/sr?q=saat,ty://?Page=Search&Query=saat,,weburl\n" +
+		"https://www.trendyol.com/sepet,ty://?Page=Basket,,deeplink\n" +
+		"https://www.trendyol.com/sr?q=etek,ty://?Page=Search&Query=etek,,\n"))
+	assert.Nil(err)
+	assert.Equal([]DiffInput{
+		{Link: "https://www.trendyol.com/sr?q=saat", ToDeepLink: true, Stored: "ty://?Page=Search&Query=saat"},
+		{Link: "ty://?Page=Basket", ToDeepLink: false, Stored: "https://www.trendyol.com/sepet"},
+		{Link: "https://www.trendyol.com/sr?q=etek", ToDeepLink: true, Stored: "ty://?Page=Search&Query=etek"},
+	}, inputs)
+	inputs, err = ReadDiffCorpus(strings.NewReader("short_url\nty://?Page=Basket\n"))
+	assert.Nil(err)
+	assert.Equal([]DiffInput{{Link: "ty://?Page=Basket", ToDeepLink: false}}, inputs)
+
+	_, err = ReadDiffCorpus(strings.NewReader("long_url,short_url\nhttps://www.trendyol.com/sr?q=saat\n"))
+	assert.NotNil(err)
+}
+
+func TestDiffRuleSets(t *testing.T) {
+	assert := assert.New(t)
+	rules := strings.Replace(string(defaultRulesFile), `version: "1"`, `version: "2"`, 1)
+	rules = strings.Replace(rules, "app: CampaignId", "app: BoutiqueId", 1)
+	rules = strings.Replace(rules, "path: /sepet", "path: /Sepetim", 1)
+	newRules, err := ParseRuleSet([]byte(rules))
+	assert.Nil(err)
+
+	inputs := []DiffInput{
+		{Link: "https://www.trendyol.com/casio/saat-p-1925865?boutiqueId=439892&utm_source=a", ToDeepLink: true},
+		{Link: "https://www.trendyol.com/sr?q=saat", ToDeepLink: true},
+		{Link: "https://www.trendyol.com/sepet", ToDeepLink: true},
+		{Link: "ty://?Page=Basket", ToDeepLink: false},
+		{Link: "not a url", ToDeepLink: true},
+	}
+	report := DiffRuleSets(DefaultRuleSet(), newRules, nil, inputs)
+	assert.Equal("1", report.OldVersion)
+	assert.Equal("2", report.NewVersion)
+	assert.Equal(5, report.Checked)
+	assert.Equal(3, report.Changed)
+	assert.Equal(2, len(report.Groups))
+
+	assert.Equal(ProductPageRule, report.Groups[0].PageType)
+	assert.Equal("ty://?Page=Product&ContentId=1925865&CampaignId=439892", report.Groups[0].Changes[0].Old)
+	assert.Equal("ty://?Page=Product&ContentId=1925865&BoutiqueId=439892", report.Groups[0].Changes[0].New)
+
+	assert.Equal(StaticPageRule, report.Groups[1].PageType)
+	assert.Equal(2, len(report.Groups[1].Changes))
+	assert.Equal(FallbackPageType, report.Groups[1].Changes[0].NewPageType)
+	assert.Equal("ty://?Page=Home", report.Groups[1].Changes[0].New)
+	assert.Equal("https://www.trendyol.com/Sepetim", report.Groups[1].Changes[1].New)
+}
+
+func TestDiffStored(t *testing.T) {
+	assert := assert.New(t)
+	inputs := []DiffInput{
+		{Link: "https://www.trendyol.com/sr?q=saat", ToDeepLink: true},
+		{Link: "ty://?Page=Basket", ToDeepLink: false},
+		{Link: "not a url", ToDeepLink: true},
+	}
+	var baseline strings.Builder
+	assert.Nil(WriteDiffBaseline(&baseline, DefaultRuleSet(), nil, inputs))
+	stored, err := ReadDiffCorpus(strings.NewReader(baseline.String()))
+	assert.Nil(err)
+	assert.Equal(3, len(stored))
+	assert.Equal(DiffInput{Link: "ty://?Page=Basket", ToDeepLink: false, Stored: "https://www.trendyol.com/sepet"}, stored[1])
+
+	// Unchanged code and rules reproduce the baseline.
+	report := DiffRuleSets(DefaultRuleSet(), DefaultRuleSet(), nil, stored)
+	assert.Equal(3, report.Checked)
+	assert.Equal(0, report.Changed)
+
+	// What production serves differs from the converter although the rules are the same, e.g. after a code change.
+	stored[0].Stored = "ty://?Page=Search&Query=Saat"
+	report = DiffRuleSets(DefaultRuleSet(), DefaultRuleSet(), nil, stored)
+	assert.Equal(1, report.Changed)
+	assert.Equal("ty://?Page=Search&Query=Saat", report.Groups[0].Changes[0].Old)
+	assert.Equal("ty://?Page=Search&Query=saat", report.Groups[0].Changes[0].New)
+}
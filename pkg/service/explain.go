@@ -0,0 +1,77 @@
+package service
+
+/*
+Trace records what a conversion extracted from the link and why it ended up on the fallback page.
+Converters are called with a nil *Trace outside explain mode, its methods do nothing then.
+*/
+
+type Trace struct {
+	Fields     map[string]string
+	Fallback   bool
+	ReasonCode FallbackReason
+	Reason     string
+}
+
+func (t *Trace) field(name string, value string) {
+	if t == nil || value == "" {
+		return
+	}
+	if t.Fields == nil {
+		t.Fields = map[string]string{}
+	}
+	t.Fields[name] = value
+}
+
+/*
+Marks the conversion as fallen back. The first reason is kept, it is the most specific one.
+*/
+
+func (t *Trace) fail(code FallbackReason, reason string) {
+	if t == nil || t.Fallback {
+		return
+	}
+	t.Fallback = true
+	t.ReasonCode = code
+	t.Reason = reason
+}
+
+/*
+Explanation is the result of a conversion with its input and the fields taken out of the link.
+*/
+
+type Explanation struct {
+	Input string `json:"input"`
+	ConversionResult
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+/*
+Converts a web URL like CreateDeepLink and explains the conversion. Nothing is looked up or stored.
+*/
+
+func (l *ConverterService) ExplainDeepLink(webURL string) (Explanation, error) {
+	t := &Trace{}
+	deepLink, pageType, err := l.convertToDeepLink(webURL, t)
+	if err != nil {
+		return Explanation{}, err
+	}
+	return t.explanation(webURL, deepLink, pageType), nil
+}
+
+/*
+Converts a deeplink like CreateWebURL and explains the conversion. Nothing is looked up or stored.
+*/
+
+func (l *ConverterService) ExplainWebURL(deepLink string) (Explanation, error) {
+	t := &Trace{}
+	webURL, pageType, err := l.convertToWebURL(deepLink, t)
+	if err != nil {
+		return Explanation{}, err
+	}
+	return t.explanation(deepLink, webURL, pageType), nil
+}
+
+func (t *Trace) explanation(input string, output string, pageType string) Explanation {
+	result := ConversionResult{Output: output, PageType: pageType, Fallback: t.Fallback, ReasonCode: t.ReasonCode, Reason: t.Reason}
+	return Explanation{Input: input, ConversionResult: result, Fields: t.Fields}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	assert := assert.New(t)
+	converterService := ConverterService{ActiveRules: NewActiveRuleSet(DefaultRuleSet())}
+
+	tests := []struct {
+		link             string
+		toDeepLink       bool
+		expectedOutput   string
+		expectedPageType string
+		expectedFields   map[string]string
+		expectedReason   string
+	}{
+		{"https://www.trendyol.com/casio/saat-p-1925865?boutiqueId=439892&merchantId=105064", true,
+			"ty://?Page=Product&ContentId=1925865&CampaignId=439892&MerchantId=105064", ProductPageRule,
+			map[string]string{"ContentId": "1925865", "boutiqueId": "439892", "merchantId": "105064"}, ""},
+		{"https://www.trendyol.com/casio/saat-p-1925865?sizeId=", true, "ty://?Page=Home", ProductPageRule,
+			map[string]string{"ContentId": "1925865"}, "Parameter sizeId has no value."},
+		{"https://www.trendyol.com/casio/saat-p-?boutiqueId=439892", true, "ty://?Page=Home", ProductPageRule,
+			nil, "Product URL has no ContentId after '-p-'."},
+		{"https://www.trendyol.com/sr?q=saat&sort=price", true, "ty://?Page=Home", SearchPageRule,
+			map[string]string{"q": "saat"}, "Parameter sort is not allowed on this page type."},
+		{"https://www.trendyol.com/sr?q=sa/at", true, "ty://?Page=Home", SearchPageRule,
+			map[string]string{"q": "sa/at"}, "Search query 'sa/at' contains '?' or '/'."},
+		{"https://www.trendyol.com/kampanyalar", true, "ty://?Page=Home", FallbackPageType,
+			nil, "No page type matches the URL."},
+		{"https://www.trendyol.com/erkek-t-shirt-x-g2-c73", true, "ty://?Page=Category&CategoryId=73&Gender=2", "category",
+			map[string]string{"CategoryId": "73", "Gender": "2"}, ""},
+		{"ty://?Page=Product&ContentId=1925865&MerchantId=105064", false,
+			"https://www.trendyol.com/brand/name-p-1925865?merchantId=105064", ProductPageRule,
+			map[string]string{"ContentId": "1925865", "MerchantId": "105064"}, ""},
+		{"ty://?Page=Product&ContentId=19%2F25", false, "https://www.trendyol.com", ProductPageRule,
+			map[string]string{"ContentId": "19/25"}, "ContentId '19/25' contains '&', '=' or '/'."},
+		{"ty://?Page=Search&Query=", false, "https://www.trendyol.com", SearchPageRule,
+			nil, "Search deeplink has no Query value."},
+		{"ty://?Page=Campaigns", false, "https://www.trendyol.com", FallbackPageType,
+			nil, "No page type matches the deeplink."},
+	}
+	for _, test := range tests {
+		explain := converterService.ExplainDeepLink
+		if !test.toDeepLink {
+			explain = converterService.ExplainWebURL
+		}
+		explanation, err := explain(test.link)
+		assert.Nil(err, test.link)
+		assert.Equal(test.link, explanation.Input)
+		assert.Equal(test.expectedOutput, explanation.Output, test.link)
+		assert.Equal(test.expectedPageType, explanation.PageType, test.link)
+		assert.Equal(test.expectedFields, explanation.Fields, test.link)
+		assert.Equal(test.expectedReason != "", explanation.Fallback, test.link)
+		assert.Equal(test.expectedReason, explanation.Reason, test.link)
+	}
+
+	_, err := converterService.ExplainDeepLink("not a url")
+	assert.NotNil(err)
+}
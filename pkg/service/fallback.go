@@ -0,0 +1,103 @@
+package service
+
+import "errors"
+
+/*
+FallbackReason tells why a conversion ended up on the fallback page.
+*/
+
+type FallbackReason string
+
+const (
+	// No page type matches the link, the fallback page is the expected result.
+	ReasonUnknownPage FallbackReason = "unknown_page"
+	// The link or its parameters can't be parsed.
+	ReasonUnparsable FallbackReason = "unparsable"
+	// The product id or the search query the page type needs is missing or empty.
+	ReasonMissingID FallbackReason = "missing_id"
+	// An allowed parameter is given without a value.
+	ReasonEmptyParam FallbackReason = "empty_param"
+	// A value contains characters the other side can't carry, e.g. '&' in a ContentId or '?' in a search query.
+	ReasonInvalidValue FallbackReason = "invalid_value"
+	// A parameter is rejected by the page type's unknown parameter policy.
+	ReasonRejectedParam FallbackReason = "rejected_param"
+	// The path or page doesn't fit the page type that claimed the link.
+	ReasonPathMismatch FallbackReason = "path_mismatch"
+)
+
+/*
+ConversionResult is a converted link with the page type that converted it. When Fallback is set the output is the
+fallback page and ReasonCode and Reason tell why.
+*/
+
+type ConversionResult struct {
+	Output     string         `json:"output"`
+	PageType   string         `json:"pageType"`
+	Fallback   bool           `json:"fallback"`
+	ReasonCode FallbackReason `json:"reasonCode,omitempty"`
+	Reason     string         `json:"reason,omitempty"`
+}
+
+/*
+A page type recognized the link but the link is malformed, e.g. a product URL with an empty boutiqueId.
+Unknown pages are not malformed.
+*/
+
+func (r ConversionResult) Malformed() bool {
+	return r.Fallback && r.ReasonCode != ReasonUnknownPage
+}
+
+var ErrMalformedLink = errors.New("Link is recognized but malformed.")
+
+/*
+MalformedLinkError is returned in strict mode instead of the fallback page.
+*/
+
+type MalformedLinkError struct {
+	Result ConversionResult
+}
+
+func (e *MalformedLinkError) Error() string {
+	return e.Result.Reason
+}
+
+func (e *MalformedLinkError) Is(target error) bool {
+	return target == ErrMalformedLink
+}
+
+/*
+Converts a web URL like CreateDeepLink and tells whether and why the result is the fallback page.
+*/
+
+func (l *ConverterService) ConvertDeepLink(webURL string) (ConversionResult, error) {
+	explanation, err := l.ExplainDeepLink(webURL)
+	return explanation.ConversionResult, err
+}
+
+/*
+Same as ConvertDeepLink in the other direction.
+*/
+
+func (l *ConverterService) ConvertWebURL(deepLink string) (ConversionResult, error) {
+	explanation, err := l.ExplainWebURL(deepLink)
+	return explanation.ConversionResult, err
+}
+
+/*
+Converts like ConvertDeepLink but returns a *MalformedLinkError instead of the fallback page for malformed links.
+*/
+
+func (l *ConverterService) StrictDeepLink(webURL string) (ConversionResult, error) {
+	return strict(l.ConvertDeepLink(webURL))
+}
+
+func (l *ConverterService) StrictWebURL(deepLink string) (ConversionResult, error) {
+	return strict(l.ConvertWebURL(deepLink))
+}
+
+func strict(result ConversionResult, err error) (ConversionResult, error) {
+	if err == nil && result.Malformed() {
+		return result, &MalformedLinkError{Result: result}
+	}
+	return result, err
+}
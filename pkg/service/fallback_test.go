@@ -0,0 +1,51 @@
+package service
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestConvertFallbackReasons(t *testing.T) {
+	assert := assert.New(t)
+	converterService := ConverterService{ActiveRules: NewActiveRuleSet(DefaultRuleSet())}
+
+	tests := []struct {
+		link               string
+		toDeepLink         bool
+		expectedLink       string
+		expectedReasonCode FallbackReason
+		expectedMalformed  bool
+	}{
+		{"https://www.trendyol.com/casio/saat-p-1925865?boutiqueId=439892", true,
+			"ty://?Page=Product&ContentId=1925865&CampaignId=439892", "", false},
+		{"https://www.trendyol.com/casio/saat-p-1925865?boutiqueId=", true, "ty://?Page=Home", ReasonEmptyParam, true},
+		{"https://www.trendyol.com/sr?q=", true, "ty://?Page=Home", ReasonMissingID, true},
+		{"https://www.trendyol.com/sr?q=saat?renk", true, "ty://?Page=Home", ReasonInvalidValue, true},
+		{"https://www.trendyol.com/sr?q=saat&sort=price", true, "ty://?Page=Home", ReasonRejectedParam, true},
+		{"https://www.trendyol.com/kampanyalar", true, "ty://?Page=Home", ReasonUnknownPage, false},
+		{"ty://?Page=Product&ContentId=1925865%26x", false, "https://www.trendyol.com", ReasonInvalidValue, true},
+		{"ty://?Page=Product&ContentId=", false, "https://www.trendyol.com", ReasonMissingID, true},
+		{"ty://?Page=Category&CategoryId=", false, "https://www.trendyol.com", ReasonEmptyParam, true},
+		{"ty://?Page=Campaigns", false, "https://www.trendyol.com", ReasonUnknownPage, false},
+	}
+	for _, test := range tests {
+		convert, convertStrict := converterService.ConvertDeepLink, converterService.StrictDeepLink
+		if !test.toDeepLink {
+			convert, convertStrict = converterService.ConvertWebURL, converterService.StrictWebURL
+		}
+		result, err := convert(test.link)
+		assert.Nil(err, test.link)
+		assert.Equal(test.expectedLink, result.Output, test.link)
+		assert.Equal(test.expectedReasonCode != "", result.Fallback, test.link)
+		assert.Equal(test.expectedReasonCode, result.ReasonCode, test.link)
+		assert.Equal(test.expectedMalformed, result.Malformed(), test.link)
+
+		_, err = convertStrict(test.link)
+		assert.Equal(test.expectedMalformed, errors.Is(err, ErrMalformedLink), test.link)
+	}
+
+	_, err := converterService.StrictDeepLink("not a url")
+	assert.NotNil(err)
+	assert.False(errors.Is(err, ErrMalformedLink))
+}
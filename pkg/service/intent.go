@@ -0,0 +1,64 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+/*
+Formats of the deeplinks returned by the API. DeepLinkFormatScheme is the 'ty://' deeplink,
+DeepLinkFormatIntent wraps it in an Android intent:// URL.
+*/
+
+const (
+	DeepLinkFormatScheme = "scheme"
+	DeepLinkFormatIntent = "intent"
+)
+
+/*
+Returns deepLink in the format, an empty format is DeepLinkFormatScheme. fallbackURL is opened by an intent
+URL when the app isn't installed.
+*/
+
+func (l *ConverterService) FormatDeepLink(deepLink string, format string, fallbackURL string) (string, error) {
+	if err := ValidateDeepLinkFormat(format); err != nil {
+		return "", err
+	}
+	if format == DeepLinkFormatIntent {
+		return l.Rules().IntentURL(deepLink, fallbackURL)
+	}
+	return deepLink, nil
+}
+
+func ValidateDeepLinkFormat(format string) error {
+	if format != "" && format != DeepLinkFormatScheme && format != DeepLinkFormatIntent {
+		return errors.New("Format should be '" + DeepLinkFormatScheme + "' or '" + DeepLinkFormatIntent + "'.")
+	}
+	return nil
+}
+
+/*
+Builds the intent URL Chrome on Android uses to open an app, e.g. 'ty://?Page=Basket' becomes
+'intent://?Page=Basket#Intent;scheme=ty;package=trendyol.com;S.browser_fallback_url=https%3A%2F%2Fwww.trendyol.com%2Fsepet;end'.
+The browser opens fallbackURL when the app isn't installed, it is left out when empty.
+*/
+
+func (rs *RuleSet) IntentURL(deepLink string, fallbackURL string) (string, error) {
+	if rs.Android.Package == "" {
+		return "", errors.New("Rules have no android package, intent URLs can't be built.")
+	}
+	idx := strings.Index(deepLink, "://")
+	if idx <= 0 {
+		return "", errors.New("Deeplink " + deepLink + " has no scheme.")
+	}
+	scheme, rest := deepLink[:idx], deepLink[idx+len("://"):]
+	// '#' starts the intent extras, it can't stay in the data URI.
+	rest = strings.ReplaceAll(rest, "#", "%23")
+
+	intent := "intent://" + rest + "#Intent;scheme=" + scheme + ";package=" + rs.Android.Package + ";"
+	if fallbackURL != "" {
+		intent += "S.browser_fallback_url=" + url.QueryEscape(fallbackURL) + ";"
+	}
+	return intent + "end", nil
+}
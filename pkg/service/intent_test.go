@@ -0,0 +1,39 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestFormatDeepLink(t *testing.T) {
+	assert := assert.New(t)
+	c := ConverterService{ActiveRules: NewActiveRuleSet(DefaultRuleSet())}
+
+	tests := []struct {
+		deepLink    string
+		format      string
+		fallbackURL string
+		expected    string
+	}{
+		{"ty://?Page=Basket", "", "https://www.trendyol.com/sepet", "ty://?Page=Basket"},
+		{"ty://?Page=Basket", DeepLinkFormatScheme, "https://www.trendyol.com/sepet", "ty://?Page=Basket"},
+		{"ty://?Page=Product&ContentId=1925865&utm_source=sms", DeepLinkFormatIntent, "https://www.trendyol.com/casio/saat-p-1925865?utm_source=sms",
+			"intent://?Page=Product&ContentId=1925865&utm_source=sms#Intent;scheme=ty;package=trendyol.com;" +
+				"S.browser_fallback_url=https%3A%2F%2Fwww.trendyol.com%2Fcasio%2Fsaat-p-1925865%3Futm_source%3Dsms;end"},
+		{"ty://?Page=Basket", DeepLinkFormatIntent, "", "intent://?Page=Basket#Intent;scheme=ty;package=trendyol.com;end"},
+	}
+	for _, test := range tests {
+		actual, err := c.FormatDeepLink(test.deepLink, test.format, test.fallbackURL)
+		assert.Nil(err, test.deepLink)
+		assert.Equal(test.expected, actual, test.deepLink)
+	}
+
+	_, err := c.FormatDeepLink("ty://?Page=Basket", "universal", "")
+	assert.NotNil(err)
+
+	rules, err := ParseRuleSet([]byte(strings.Replace(string(defaultRulesFile), "  package: trendyol.com\n", "", 1)))
+	assert.Nil(err)
+	_, err = rules.IntentURL("ty://?Page=Basket", "")
+	assert.NotNil(err)
+}
@@ -6,15 +6,88 @@ import (
 	"log"
 	"net/url"
 	"strings"
+	"time"
+	"trendyolcase/pkg/model"
 	"trendyolcase/pkg/repository/link"
 )
 
+/*
+LinkRepository stores the converted links and the request logs.
+Implementations live in pkg/repository/link (Postgres, SQLite and in-memory).
+*/
+
+type LinkRepository interface {
+	GetDeepLinkIfWebURLExist(webURL string) (string, error)
+	GetWebURLIfDeepLinkExist(deepLink string) (string, error)
+	GetDeepLinks(webURLs []string) (map[string]string, error)
+	GetWebURLs(deepLinks []string) (map[string]string, error)
+	GetOrCreate(m model.Mapping) (model.Link, error)
+	AssignCode(webURL string, code string) (string, error)
+	GetByCode(code string) (model.Link, error)
+	RecordClick(click model.Click) error
+	ClaimClickByToken(token string, since time.Time) (model.Click, error)
+	ClaimClickByFingerprint(fingerprint string, since time.Time) (model.Click, error)
+	InsertLog(logInformation string) bool
+}
+
 type ConverterService struct {
-	ConverterRepository *link.Repository
+	ConverterRepository LinkRepository
+	ActiveRules         *ActiveRuleSet
+	Catalog             ProductCatalog
+	// Generates the short codes of shortened mappings, RandomCode when nil.
+	CodeGenerator func() (string, error)
+	// How long after the click the app can claim a deferred deeplink, DefaultDeferredWindow when zero.
+	DeferredWindow time.Duration
+}
+
+func NewConverterService(l LinkRepository) ConverterService {
+	return ConverterService{ConverterRepository: l, ActiveRules: NewActiveRuleSet(DefaultRuleSet())}
+}
+
+/*
+Rule set in use. It can be replaced while the service is running, so callers should read it once per conversion.
+*/
+
+func (l *ConverterService) Rules() *RuleSet {
+	return l.ActiveRules.Load()
 }
 
-func NewConverterService(l *link.Repository) ConverterService {
-	return ConverterService{ConverterRepository: l}
+/*
+Copy of the service pinned to the rules active now, reloads don't reach it. A request converts with one,
+so every step of it and the rules version it reports use the same rule set.
+*/
+
+func (l *ConverterService) Snapshot() ConverterService {
+	snapshot := *l
+	snapshot.ActiveRules = NewActiveRuleSet(l.Rules())
+	return snapshot
+}
+
+func (l *ConverterService) RulesVersion() string {
+	return l.Rules().Version
+}
+
+/*
+Version of the conversion code, bump it when a converter is fixed so the stored mappings are recomputed by the backfill.
+*/
+
+const converterCodeVersion = "1"
+
+/*
+Stored mappings are tagged with the converter version, e.g. '1/1' for converter code 1 and rules version 1.
+*/
+
+func (l *ConverterService) ConverterVersion() string {
+	return converterCodeVersion + "/" + l.RulesVersion()
+}
+
+func (l *ConverterService) mapping(webURL string, deepLink string, attribution Attribution, source string) model.Mapping {
+	return model.Mapping{
+		Link:             model.Link{WebUrl: webURL, Deeplink: deepLink},
+		Attribution:      attribution.Encode(),
+		ConverterVersion: l.ConverterVersion(),
+		Source:           source,
+	}
 }
 
 func (l *ConverterService) GetDeepLinkIfWebURLExist(webURL string) (string, error) {
@@ -25,44 +98,154 @@ func (l *ConverterService) GetWebURLIfDeepLinkExist(deepLink string) (string, er
 	return l.ConverterRepository.GetWebURLIfDeepLinkExist(deepLink)
 }
 
-func (l *ConverterService) Insert(webURL string, deepLink string) bool {
-	return l.ConverterRepository.Insert(webURL, deepLink)
+/*
+Returns the deeplink stored for the webURL, or converts the webURL and stores the pair. created is true when this call stored it.
+When another request stored the webURL first its deeplink is returned. When the deeplink already belongs to another webURL
+(e.g. the same product with other slugs) the pair isn't stored and the deeplink of the stored pair is returned.
+A webURL converted to the fallback page is neither stored nor looked up, see resolveDeepLink.
+Write failures are returned as *link.WriteError.
+*/
+
+func (l *ConverterService) GetOrCreateDeepLink(webURL string, attribution Attribution) (deepLink string, created bool, err error) {
+	result, created, err := l.GetOrCreateDeepLinkResult(webURL, attribution)
+	return result.Output, created, err
+}
+
+/*
+Same as GetOrCreateDeepLink, the result has the returned deeplink as output and tells whether it is the fallback page and why.
+*/
+
+func (l *ConverterService) GetOrCreateDeepLinkResult(webURL string, attribution Attribution) (result ConversionResult, created bool, err error) {
+	_, result, created, err = l.getOrCreateDeepLink(webURL, attribution)
+	return result, created, err
+}
+
+func (l *ConverterService) getOrCreateDeepLink(webURL string, attribution Attribution) (stored model.Link, result ConversionResult, created bool, err error) {
+	storedDeepLink, _ := l.ConverterRepository.GetDeepLinkIfWebURLExist(webURL)
+	return l.resolveDeepLink(webURL, attribution, storedDeepLink)
 }
 
+/*
+Converts the webURL and returns the conversion with storedDeepLink, the deeplink stored for the webURL ("" when there
+is none), as output or stores the conversion. Only a conversion which isn't a fallback is replaced by a stored link, so
+the fallback fields of the result describe the returned output. stored is the pair the output belongs to, it belongs to another webURL
+when the conversion conflicts.
+The fallback page is the conversion of every unknown or malformed link, so a fallback conversion is returned as it is
+and stored is empty: a stored fallback pair would be served for the fallback page in the other direction. A stored
+fallback page, from rules which didn't know the page yet, isn't returned either.
+*/
+
+func (l *ConverterService) resolveDeepLink(webURL string, attribution Attribution, storedDeepLink string) (stored model.Link, result ConversionResult, created bool, err error) {
+	result, err = l.ConvertDeepLink(webURL)
+	if err != nil || result.Fallback {
+		return model.Link{}, result, false, err
+	}
+	fallback := l.Rules().Fallback.DeepLink
+	if storedDeepLink != "" && storedDeepLink != fallback {
+		result.Output = storedDeepLink
+		return model.Link{WebUrl: webURL, Deeplink: storedDeepLink}, result, false, nil
+	}
+	stored, err = l.ConverterRepository.GetOrCreate(l.mapping(webURL, result.Output, attribution, model.SourceWebURL))
+	var conflict *link.ConflictError
+	if errors.As(err, &conflict) {
+		if conflict.Stored.Deeplink == fallback {
+			return model.Link{}, result, false, nil
+		}
+		if conflict.Stored.WebUrl != webURL {
+			_ = l.InsertLog(conflict.Error() + " The stored pair is returned.")
+		}
+		result.Output = conflict.Stored.Deeplink
+		return conflict.Stored, result, false, nil
+	}
+	if err != nil {
+		return model.Link{}, ConversionResult{}, false, err
+	}
+	return stored, result, true, nil
+}
+
+/*
+Same as GetOrCreateDeepLink in the other direction.
+*/
+
+func (l *ConverterService) GetOrCreateWebURL(deepLink string, attribution Attribution) (webURL string, created bool, err error) {
+	result, created, err := l.GetOrCreateWebURLResult(deepLink, attribution)
+	return result.Output, created, err
+}
+
+func (l *ConverterService) GetOrCreateWebURLResult(deepLink string, attribution Attribution) (result ConversionResult, created bool, err error) {
+	_, result, created, err = l.getOrCreateWebURL(deepLink, attribution)
+	return result, created, err
+}
+
+func (l *ConverterService) getOrCreateWebURL(deepLink string, attribution Attribution) (stored model.Link, result ConversionResult, created bool, err error) {
+	storedWebURL, _ := l.ConverterRepository.GetWebURLIfDeepLinkExist(deepLink)
+	return l.resolveWebURL(deepLink, attribution, storedWebURL)
+}
+
+func (l *ConverterService) resolveWebURL(deepLink string, attribution Attribution, storedWebURL string) (stored model.Link, result ConversionResult, created bool, err error) {
+	result, err = l.ConvertWebURL(deepLink)
+	if err != nil || result.Fallback {
+		return model.Link{}, result, false, err
+	}
+	fallback := l.Rules().Fallback.WebURL
+	if storedWebURL != "" && storedWebURL != fallback {
+		result.Output = storedWebURL
+		return model.Link{WebUrl: storedWebURL, Deeplink: deepLink}, result, false, nil
+	}
+	stored, err = l.ConverterRepository.GetOrCreate(l.mapping(result.Output, deepLink, attribution, model.SourceDeepLink))
+	var conflict *link.ConflictError
+	if errors.As(err, &conflict) {
+		if conflict.Stored.WebUrl == fallback {
+			return model.Link{}, result, false, nil
+		}
+		if conflict.Stored.Deeplink != deepLink {
+			_ = l.InsertLog(conflict.Error() + " The stored pair is returned.")
+		}
+		result.Output = conflict.Stored.WebUrl
+		return conflict.Stored, result, false, nil
+	}
+	if err != nil {
+		return model.Link{}, ConversionResult{}, false, err
+	}
+	return stored, result, true, nil
+}
+
+/*
+Every log carries the version of the rules it was produced with.
+*/
+
 func (l *ConverterService) InsertLog(logInformation string) bool {
-	return l.ConverterRepository.InsertLog(logInformation)
+	return l.ConverterRepository.InsertLog("Rules version= " + l.RulesVersion() + ". " + logInformation)
 }
 
 /*
 Deeplink doesn't exist in db so create a deeplink.
-First check is the request URL about product detail page, search page or other page.
-After that return response created deeplink.
-If request is not url, bad request is returned. In all other faulty cases, the other page is returned.
+The registered rules are asked in order whether they handle the request URL, the first match converts it.
+If request is not url, bad request is returned. If no rule matches, the other page is returned.
 */
 
 func (l *ConverterService) CreateDeepLink(requestLink string) (string, error) {
+	responseDeepLink, _, err := l.convertToDeepLink(requestLink, nil)
+	return responseDeepLink, err
+}
 
-	const (
-		trendyolHomePage     = "https://www.trendyol.com"
-		trendyolSearchPage   = "https://www.trendyol.com/sr?q="
-		deepLinkHomePage     = "ty://?Page=Home"
-		productPageSeperator = "-p-"
-	)
+/*
+CreateDeepLink which also returns the page type (FallbackPageType when no rule matches) and records the conversion in t.
+*/
 
+func (l *ConverterService) convertToDeepLink(requestLink string, t *Trace) (string, string, error) {
 	if !(govalidator.IsURL(requestLink)) {
-		return "", errors.New("There is an error in the requested data. Check the data. Tag should be 'weburl' and links doesn't contain space")
+		return "", "", errors.New("There is an error in the requested data. Check the data. Tag should be 'weburl' and links doesn't contain space")
 	}
 
-	if strings.HasPrefix(requestLink, trendyolHomePage) && strings.Contains(requestLink, productPageSeperator) {
-		responseDeepLink := ConvertProductDetailPageToDeepLink(requestLink)
-		return responseDeepLink, nil
-	} else if strings.HasPrefix(requestLink, trendyolSearchPage) {
-		responseDeepLink := ConvertSearchPageToDeepLink(requestLink)
-		return responseDeepLink, nil
-	} else {
-		responseDeepLink := ConvertOtherPageToDeepLink(deepLinkHomePage)
-		return responseDeepLink, nil
+	rules := l.Rules()
+	if rule, ok := rules.MatchWebURL(requestLink); ok {
+		responseDeepLink := rule.traceToDeepLink(requestLink, t)
+		return responseDeepLink, rule.Name, nil
 	}
+	t.fail(ReasonUnknownPage, "No page type matches the URL.")
+	responseDeepLink := ConvertOtherPageToDeepLink(rules.Fallback.DeepLink)
+	return responseDeepLink, FallbackPageType, nil
 }
 
 /*
@@ -70,54 +253,42 @@ Converts to product page URL to product page deeplink.
 */
 
 func ConvertProductDetailPageToDeepLink(requestLink string) string {
-	const (
-		productPageDeepLinkBase = "ty://?Page=Product&ContentId="
-		deepLinkHomePage        = "ty://?Page=Home"
-		boutiqueIDSeperator     = "boutiqueId"
-		merchantIDSeparator     = "merchantId"
-	)
-	query := strings.Split(requestLink, "-p-")[1]
-
-	u, _ := url.Parse(requestLink)
-	q, _ := url.ParseQuery(u.RawQuery)
+	return defaultRules.ConvertProductDetailPageToDeepLink(requestLink)
+}
 
-	boutiqueID := q.Get(boutiqueIDSeperator)
-	merchantID := q.Get(merchantIDSeparator)
+func (rs *RuleSet) ConvertProductDetailPageToDeepLink(requestLink string) string {
+	return rs.productPageToDeepLink(requestLink, nil)
+}
 
-	boutiqueIDFlag := q.Has(boutiqueIDSeperator)
-	merchantIDFlag := q.Has(merchantIDSeparator)
-	badRequestWithCampaignID := boutiqueIDFlag && boutiqueID == ""
-	badRequestWithMerchantID := merchantIDFlag && merchantID == ""
+func (rs *RuleSet) productPageToDeepLink(requestLink string, t *Trace) string {
+	productPageDeepLinkBase := rs.deepLinkBase(rs.Product.Page) + "&" + rs.Product.IDParam + "="
+	deepLinkHomePage := rs.Fallback.DeepLink
 
-	if badRequestWithCampaignID || badRequestWithMerchantID {
+	query := strings.Split(requestLink, rs.Product.PathSeparator)[1]
+
+	u, err := url.Parse(requestLink)
+	if err != nil {
+		t.fail(ReasonUnparsable, "URL can't be parsed: "+err.Error()+".")
 		return deepLinkHomePage
 	}
 
-	idx := strings.Index(query, "?")
+	contentID := query
+	if idx := strings.Index(query, "?"); idx != -1 {
+		contentID = query[:idx]
+	}
+	if contentID == "" {
+		t.fail(ReasonMissingID, "Product URL has no "+rs.Product.IDParam+" after '"+rs.Product.PathSeparator+"'.")
+		return deepLinkHomePage
+	}
+	t.field(rs.Product.IDParam, contentID)
 
-	if idx != -1 {
-		contentID := query[:idx]
-		responseDeepLink := productPageDeepLinkBase + contentID
-		if contentID == "" {
-			return deepLinkHomePage
-		}
-		if boutiqueID != "" && merchantID != "" {
-			responseDeepLink := responseDeepLink + "&CampaignId=" + boutiqueID + "&MerchantId=" + merchantID
-			return responseDeepLink
-		} else if merchantID != "" {
-			responseDeepLink := responseDeepLink + "&MerchantId=" + merchantID
-			return responseDeepLink
-		} else if boutiqueID != "" {
-			responseDeepLink := responseDeepLink + "&CampaignId=" + boutiqueID
-			return responseDeepLink
-		} else {
-			responseDeepLink := deepLinkHomePage
-			return responseDeepLink
-		}
-	} else {
-		responseDeepLink := productPageDeepLinkBase + query
-		return responseDeepLink
+	// Allowed parameters are renamed, the others follow the product's unknown parameter policy.
+	params, ok := mapQueryParams(queryPieces(u.RawQuery), rs.Product.QueryParams, true, rs.Product.UnknownParams.ToDeepLink, t)
+	if !ok {
+		return deepLinkHomePage
 	}
+	responseDeepLink := appendRawQuery(productPageDeepLinkBase+contentID, "&", params)
+	return responseDeepLink
 }
 
 /*
@@ -125,29 +296,43 @@ Converts to search page URL to search page deeplink. If query is empty returns h
 */
 
 func ConvertSearchPageToDeepLink(requestLink string) string {
-	const (
-		deepLinkBaseSearch     = "ty://?Page=Search&Query="
-		querySeparator         = "q="
-		specificTurkishLetters = "çÇğĞıİöÖşŞüÜ"
-		deepLinkHomePage       = "ty://?Page=Home"
-	)
-	query := strings.Split(requestLink, querySeparator)[1]
-
-	if query == "" || strings.ContainsAny(query, "?&/") {
-		return deepLinkHomePage
+	return defaultRules.ConvertSearchPageToDeepLink(requestLink)
+}
+
+func (rs *RuleSet) ConvertSearchPageToDeepLink(requestLink string) string {
+	return rs.searchPageToDeepLink(requestLink, nil)
+}
+
+func (rs *RuleSet) searchPageToDeepLink(requestLink string, t *Trace) string {
+	deepLinkBaseSearch := rs.deepLinkBase(rs.Search.Page) + "&" + rs.Search.QueryParam.App + "="
+	querySeparator := rs.Search.QueryParam.Web + "="
+
+	pieces := strings.Split(strings.Split(requestLink, querySeparator)[1], "&")
+	query := pieces[0]
+
+	if query == "" {
+		t.fail(ReasonMissingID, "Search URL has an empty '"+rs.Search.QueryParam.Web+"' parameter.")
+		return rs.Fallback.DeepLink
+	}
+	t.field(rs.Search.QueryParam.Web, query)
+	if strings.ContainsAny(query, "?/") {
+		t.fail(ReasonInvalidValue, "Search query '"+query+"' contains '?' or '/'.")
+		return rs.Fallback.DeepLink
+	}
+	params, ok := mapQueryParams(queryPieces(strings.Join(pieces[1:], "&")), rs.Search.QueryParams, true, rs.Search.UnknownParams.ToDeepLink, t)
+	if !ok {
+		return rs.Fallback.DeepLink
 	}
 
-	if strings.ContainsAny(query, specificTurkishLetters) {
-		modifiedQuery := url.QueryEscape(query)
-		responseDeepLink := deepLinkBaseSearch + modifiedQuery
-		return responseDeepLink
+	if rs.Search.EscapeCharacters != "" && strings.ContainsAny(query, rs.Search.EscapeCharacters) {
+		query = url.QueryEscape(query)
 	}
-	responseDeepLink := deepLinkBaseSearch + query
+	responseDeepLink := appendRawQuery(deepLinkBaseSearch+query, "&", params)
 	return responseDeepLink
 }
 
 /*
-Converts to all other urls like 'https://www.trendyol.com/Hesabim/Favoriler' to 'ty://?Page=Home'.
+Converts to all other urls like 'https://www.trendyol.com/Hesabim/Adreslerim' to 'ty://?Page=Home'.
 */
 
 func ConvertOtherPageToDeepLink(deepLinkHomePage string) string {
@@ -156,35 +341,36 @@ func ConvertOtherPageToDeepLink(deepLinkHomePage string) string {
 
 /*
 webURL doesn't exist in db so create a webURL.
-First check is the deeplink about product detail page, search page or other page.
-After that return response created webURL.
+The registered rules are asked in order whether they handle the deeplink, the first match converts it.
+If no rule matches, the other page is returned.
 */
 
 func (l *ConverterService) CreateWebURL(requestLink string) (string, error) {
-	const (
-		trendyolHomePageURL     = "https://www.trendyol.com"
-		searchPageDeepLinkBase  = "ty://?Page=Search&Query="
-		productPageDeepLinkBase = "ty://?Page=Product&ContentId="
-	)
+	responseWebURL, _, err := l.convertToWebURL(requestLink, nil)
+	return responseWebURL, err
+}
+
+/*
+CreateWebURL which also returns the page type (FallbackPageType when no rule matches) and records the conversion in t.
+*/
 
+func (l *ConverterService) convertToWebURL(requestLink string, t *Trace) (string, string, error) {
 	if requestLink == "" || strings.Contains(requestLink, " ") {
-		return "", errors.New("There is an error in the requested data. Check the data. Tag should be 'deeplink' and links doesn't contain space")
+		return "", "", errors.New("There is an error in the requested data. Check the data. Tag should be 'deeplink' and links doesn't contain space")
 	}
 
-	if strings.HasPrefix(requestLink, searchPageDeepLinkBase) {
-		responseWebURL := ConvertSearchPageToURL(requestLink)
-		return responseWebURL, nil
-	} else if strings.HasPrefix(requestLink, productPageDeepLinkBase) {
-		responseWebURL := ConvertProductDetailPageToURL(requestLink)
-		return responseWebURL, nil
-	} else {
-		responseWebURL := ConvertOtherPageToURL(trendyolHomePageURL)
-		return responseWebURL, nil
+	rules := l.Rules()
+	if rule, ok := rules.MatchDeepLink(requestLink); ok {
+		responseWebURL := l.withProductSlug(rules, rule.traceToWebURL(requestLink, t))
+		return responseWebURL, rule.Name, nil
 	}
+	t.fail(ReasonUnknownPage, "No page type matches the deeplink.")
+	responseWebURL := ConvertOtherPageToURL(rules.Fallback.WebURL)
+	return responseWebURL, FallbackPageType, nil
 }
 
 /*
-Converts to all other deeplinks like 'ty://?Page=Favorites' to 'www.trendyol.com'.
+Converts to all other deeplinks like 'ty://?Page=Addresses' to 'www.trendyol.com'.
 */
 
 func ConvertOtherPageToURL(trendyolHomePage string) string {
@@ -196,21 +382,42 @@ Converts deeplink search page to search page URL.
 */
 
 func ConvertSearchPageToURL(requestLink string) string {
+	return defaultRules.ConvertSearchPageToURL(requestLink)
+}
+
+func (rs *RuleSet) ConvertSearchPageToURL(requestLink string) string {
+	return rs.searchPageToURL(requestLink, nil)
+}
+
+func (rs *RuleSet) searchPageToURL(requestLink string, t *Trace) string {
+	baseSearchWebURL := rs.WebBaseURL + rs.Search.WebPath + "?" + rs.Search.QueryParam.Web + "="
+	querySeparator := rs.Search.QueryParam.App
 
-	const (
-		baseSearchWebURL = "https://www.trendyol.com/sr?q="
-		querySeparator   = "Query"
-	)
-	u, _ := url.Parse(requestLink)
+	u, err := url.Parse(requestLink)
+	if err != nil {
+		t.fail(ReasonUnparsable, "Deeplink can't be parsed: "+err.Error()+".")
+		return rs.Fallback.WebURL
+	}
 	q, _ := url.ParseQuery(u.RawQuery)
 
 	query := q.Get(querySeparator)
+	t.field(querySeparator, query)
 	query = url.QueryEscape(query)
 
-	if !(strings.Contains(requestLink, "ty://?Page=Search&Query")) || query == "" || strings.Contains(query, "?") {
-		return "https://www.trendyol.com"
+	if !(strings.Contains(requestLink, rs.deepLinkBase(rs.Search.Page)+"&"+querySeparator)) || query == "" {
+		t.fail(ReasonMissingID, "Search deeplink has no "+querySeparator+" value.")
+		return rs.Fallback.WebURL
 	}
-	responseWebURL := baseSearchWebURL + query
+	if strings.Contains(query, "?") {
+		t.fail(ReasonInvalidValue, "Search query contains '?'.")
+		return rs.Fallback.WebURL
+	}
+	pieces := queryPiecesWithout(u.RawQuery, rs.PageKey, querySeparator)
+	params, ok := mapQueryParams(pieces, rs.Search.QueryParams, false, rs.Search.UnknownParams.ToWebURL, t)
+	if !ok {
+		return rs.Fallback.WebURL
+	}
+	responseWebURL := appendRawQuery(baseSearchWebURL+query, "&", params)
 	return responseWebURL
 }
 
@@ -219,52 +426,55 @@ Converts deeplink product page to product page URL.
 */
 
 func ConvertProductDetailPageToURL(requestLink string) string {
-	const (
-		baseWebURL          = "https://www.trendyol.com/brand/name-p-"
-		campaignIDSeperator = "CampaignId"
-		merchantIDSeparator = "MerchantId"
-		contentIDSeparator  = "ContentId"
-	)
+	return defaultRules.ConvertProductDetailPageToURL(requestLink)
+}
+
+func (rs *RuleSet) ConvertProductDetailPageToURL(requestLink string) string {
+	return rs.productPageToURL(requestLink, nil)
+}
+
+func (rs *RuleSet) productPageToURL(requestLink string, t *Trace) string {
+	baseWebURL := rs.productPlaceholderURL()
+	contentIDSeparator := rs.Product.IDParam
 
 	u, err := url.Parse(requestLink)
 	if err != nil {
 		log.Printf("%s", err)
+		t.fail(ReasonUnparsable, "Deeplink can't be parsed: "+err.Error()+".")
+		return rs.Fallback.WebURL
 	}
 	q, err := url.ParseQuery(u.RawQuery)
 	if err != nil {
 		log.Printf("%s", err)
 	}
 
-	campaignID := q.Get(campaignIDSeperator)
-	merchantID := q.Get(merchantIDSeparator)
 	contentID := q.Get(contentIDSeparator)
-
+	t.field(contentIDSeparator, contentID)
 	responseWebURL := baseWebURL + contentID
 
-	campaignIDFlag := q.Has(campaignIDSeperator)
-	merchantIDFlag := q.Has(merchantIDSeparator)
-	contentIDFlag := q.Has(contentIDSeparator)
-
-	badRequestWithCampaignID := campaignIDFlag && campaignID == ""
-	badRequestWithMerchantID := merchantIDFlag && merchantID == ""
-	badRequestWithContentID := contentIDFlag && contentID == ""
-
-	if badRequestWithCampaignID || badRequestWithMerchantID || badRequestWithContentID {
-		return "https://www.trendyol.com"
+	badRequestWithContentID := q.Has(contentIDSeparator) && contentID == ""
+	if badRequestWithContentID {
+		t.fail(ReasonMissingID, "Product deeplink has an empty "+contentIDSeparator+".")
+		return rs.Fallback.WebURL
+	}
+	if strings.ContainsAny(contentID, "&=/") {
+		t.fail(ReasonInvalidValue, contentIDSeparator+" '"+contentID+"' contains '&', '=' or '/'.")
+		return rs.Fallback.WebURL
 	}
 
-	if campaignIDFlag && merchantIDFlag {
-		responseDeepLink := responseWebURL + "?boutiqueId=" + campaignID + "&merchantId=" + merchantID
-		return responseDeepLink
-	} else if merchantIDFlag {
-		responseDeepLink := responseWebURL + "?merchantId=" + merchantID
-		return responseDeepLink
-	} else if campaignIDFlag {
-		responseDeepLink := responseWebURL + "?boutiqueId=" + campaignID
-		return responseDeepLink
-	} else if strings.ContainsAny(contentID, "&=/") {
-		return "https://www.trendyol.com"
-	} else {
-		return responseWebURL
+	// Allowed parameters are renamed, the others follow the product's unknown parameter policy.
+	pieces := queryPiecesWithout(u.RawQuery, rs.PageKey, contentIDSeparator)
+	params, ok := mapQueryParams(pieces, rs.Product.QueryParams, false, rs.Product.UnknownParams.ToWebURL, t)
+	if !ok {
+		return rs.Fallback.WebURL
 	}
+	return appendRawQuery(responseWebURL, "?", params)
+}
+
+/*
+Product URL up to the content id with the placeholder slugs, e.g. 'https://www.trendyol.com/brand/name-p-'.
+*/
+
+func (rs *RuleSet) productPlaceholderURL() string {
+	return rs.WebBaseURL + rs.Product.DefaultWebPath + rs.Product.PathSeparator
 }
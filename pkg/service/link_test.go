@@ -1,29 +1,16 @@
 package service
 
 import (
-	"database/sql"
 	"errors"
-	"fmt"
 	"github.com/stretchr/testify/assert"
-	"os"
 	"testing"
+	"trendyolcase/pkg/model"
 	"trendyolcase/pkg/repository/link"
 )
 
-/*
-Initialize db for use in tests.
-*/
-func dbInıt() *sql.DB {
-	var DB *sql.DB
-	connectionString := fmt.Sprintf(os.Getenv("CONNECTION_STRING"))
-	DB, _ = sql.Open("postgres", connectionString)
-	return DB
-}
-
 func TestCreateWebURL(t *testing.T) {
 	assert := assert.New(t)
-	db := dbInıt()
-	converterRepositoryTest := link.NewRepository(db)
+	converterRepositoryTest := link.NewMemoryRepository()
 	c := NewConverterService(converterRepositoryTest)
 
 	deepLinks := []struct {
@@ -32,7 +19,12 @@ func TestCreateWebURL(t *testing.T) {
 	}{
 
 		{"ty://?Page= TestWithSpaceChar", ""},
-		{"ty://?Page=Favorites", "https://www.trendyol.com"},
+		{"ty://?Page=Favorites", "https://www.trendyol.com/Hesabim/Favoriler"},
+		{"ty://?Page=Orders", "https://www.trendyol.com/Hesabim/Siparislerim"},
+		{"ty://?Page=Basket", "https://www.trendyol.com/sepet"},
+		{"ty://?Page=Coupons", "https://www.trendyol.com/Hesabim/IndirimKuponlari"},
+		{"ty://?Page=AccountSettings", "https://www.trendyol.com/Hesabim/KullaniciBilgileri"},
+		{"ty://?Page=Addresses", "https://www.trendyol.com"},
 		{"ty://?Page=Siparişlerim", "https://www.trendyol.com"},
 		{"ty://?Page=", "https://www.trendyol.com"},
 		{"ty://?Page?", "https://www.trendyol.com"},
@@ -127,9 +119,7 @@ func TestConvertProductDetailPageToURL(t *testing.T) {
 
 func TestCreateDeepLink(t *testing.T) {
 	assert := assert.New(t)
-	db := dbInıt()
-
-	converterRepository := link.NewRepository(db)
+	converterRepository := link.NewMemoryRepository()
 	c := NewConverterService(converterRepository)
 
 	webURLs := []struct {
@@ -137,7 +127,12 @@ func TestCreateDeepLink(t *testing.T) {
 		expectedDeepLink  string
 	}{
 		{"https://www.trendyol.com/ WithSpace", ""},
-		{"https://www.trendyol.com/Hesabim/Favoriler", "ty://?Page=Home"},
+		{"https://www.trendyol.com/Hesabim/Favoriler", "ty://?Page=Favorites"},
+		{"https://www.trendyol.com/Hesabim/Siparislerim", "ty://?Page=Orders"},
+		{"https://www.trendyol.com/sepet", "ty://?Page=Basket"},
+		{"https://www.trendyol.com/Hesabim/IndirimKuponlari", "ty://?Page=Coupons"},
+		{"https://www.trendyol.com/Hesabim/KullaniciBilgileri/", "ty://?Page=AccountSettings"},
+		{"https://www.trendyol.com/Hesabim/Adreslerim", "ty://?Page=Home"},
 		{"https://www.trendyol.com", "ty://?Page=Home"},
 		{"https://www.trendyol.com/sr?q=%C3%BCt%C3%BC", "ty://?Page=Search&Query=%C3%BCt%C3%BC"},
 		{"https://www.trendyol.com/sr?q=elbise", "ty://?Page=Search&Query=elbise"},
@@ -187,7 +182,7 @@ func TestConvertProductDetailPageToDeepLink(t *testing.T) {
 		{"https://www.trendyol.com/testbrand/saat-p-1", "ty://?Page=Product&ContentId=1"},
 		{"https://www.trendyol.com/casio/erkek-kol-saati-p-1925865?boutiqueId=439892", "ty://?Page=Product&ContentId=1925865&CampaignId=439892"},
 		{"https://www.trendyol.com/casio/erkek-kol-saati-p-1925865?merchantId=105064", "ty://?Page=Product&ContentId=1925865&MerchantId=105064"},
-		{"https://www.trendyol.com/testbrand/erkek-kol-saati-p-4444?MerchantId=5555", "ty://?Page=Home"},
+		{"https://www.trendyol.com/testbrand/erkek-kol-saati-p-4444?MerchantId=5555", "ty://?Page=Product&ContentId=4444"},
 	}
 	for _, v := range webURLs {
 		actualWebURL := ConvertProductDetailPageToDeepLink(v.testWebURL)
@@ -195,3 +190,74 @@ func TestConvertProductDetailPageToDeepLink(t *testing.T) {
 	}
 
 }
+
+func TestGetOrCreateDeepLink(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterService(link.NewMemoryRepository())
+
+	links := []struct {
+		webURL           string
+		expectedDeepLink string
+		expectedCreated  bool
+	}{
+		{"https://www.trendyol.com/casio/saat-p-1925865", "ty://?Page=Product&ContentId=1925865", true},
+		{"https://www.trendyol.com/casio/saat-p-1925865", "ty://?Page=Product&ContentId=1925865", false},
+		// The deeplink is stored for the first webURL, other slugs get the stored pair's deeplink.
+		{"https://www.trendyol.com/brand/name-p-1925865", "ty://?Page=Product&ContentId=1925865", false},
+		{"https://www.trendyol.com/sr?q=saat", "ty://?Page=Search&Query=saat", true},
+	}
+	for _, test := range links {
+		deepLink, created, err := c.GetOrCreateDeepLink(test.webURL, Attribution{})
+		assert.Nil(err)
+		assert.Equal(test.expectedDeepLink, deepLink)
+		assert.Equal(test.expectedCreated, created)
+	}
+
+	webURL, created, err := c.GetOrCreateWebURL("ty://?Page=Product&ContentId=1925865", Attribution{})
+	assert.Nil(err)
+	assert.False(created)
+	assert.Equal("https://www.trendyol.com/casio/saat-p-1925865", webURL)
+
+	_, _, err = c.GetOrCreateDeepLink("not a url", Attribution{})
+	assert.NotNil(err)
+	assert.False(errors.Is(err, link.ErrWrite))
+}
+
+func TestFallbackConversionsAreNotStored(t *testing.T) {
+	assert := assert.New(t)
+	repository := link.NewMemoryRepository()
+	c := NewConverterService(repository)
+
+	webURL, created, err := c.GetOrCreateWebURL("ty://?Page=Product&ContentId=5&CampaignId=", Attribution{})
+	assert.Nil(err)
+	assert.False(created)
+	assert.Equal("https://www.trendyol.com", webURL)
+	deepLink, _, err := c.GetOrCreateDeepLink("https://www.trendyol.com", Attribution{})
+	assert.Nil(err)
+	assert.Equal("ty://?Page=Home", deepLink)
+
+	deepLink, created, err = c.GetOrCreateDeepLink("https://www.trendyol.com/Hesabim/Adreslerim", Attribution{})
+	assert.Nil(err)
+	assert.False(created)
+	assert.Equal("ty://?Page=Home", deepLink)
+	results, err := c.BatchWebURLs([]string{"ty://?Page=Home"})
+	assert.Nil(err)
+	assert.Equal("https://www.trendyol.com", results[0].Output)
+
+	// Fallback pairs stored by earlier versions aren't served, in either direction.
+	_, err = repository.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/Hesabim/Adreslerim", Deeplink: "ty://?Page=Home"}})
+	assert.Nil(err)
+	_, err = repository.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com", Deeplink: "ty://?Page=Favorites"}})
+	assert.Nil(err)
+	webURL, _, err = c.GetOrCreateWebURL("ty://?Page=Home", Attribution{})
+	assert.Nil(err)
+	assert.Equal("https://www.trendyol.com", webURL)
+	webURL, created, err = c.GetOrCreateWebURL("ty://?Page=Favorites", Attribution{})
+	assert.Nil(err)
+	assert.False(created)
+	assert.Equal("https://www.trendyol.com/Hesabim/Favoriler", webURL)
+	results, err = c.BatchWebURLs([]string{"ty://?Page=Home", "ty://?Page=Favorites"})
+	assert.Nil(err)
+	assert.Equal("https://www.trendyol.com", results[0].Output)
+	assert.Equal("https://www.trendyol.com/Hesabim/Favoriler", results[1].Output)
+}
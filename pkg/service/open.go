@@ -0,0 +1,138 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+/*
+Platform is the kind of client opening a shared link, told from its User-Agent.
+*/
+
+type Platform string
+
+const (
+	PlatformIOS     Platform = "ios"
+	PlatformAndroid Platform = "android"
+	PlatformDesktop Platform = "desktop"
+	PlatformCrawler Platform = "crawler"
+)
+
+/*
+Lowercased User-Agent parts of link preview bots and search engine crawlers.
+*/
+
+var crawlerUserAgents = []string{
+	"bot", "crawler", "spider", "facebookexternalhit", "facebookcatalog", "whatsapp", "slack", "telegram",
+	"discord", "embedly", "pinterest", "vkshare", "skypeuripreview", "google-inspectiontool", "lighthouse",
+}
+
+/*
+Crawlers come first, e.g. Googlebot's smartphone User-Agent also says Android. Everything that isn't iOS or Android
+is a desktop, including empty User-Agents.
+*/
+
+func DetectPlatform(userAgent string) Platform {
+	ua := strings.ToLower(userAgent)
+	for _, crawler := range crawlerUserAgents {
+		if strings.Contains(ua, crawler) {
+			return PlatformCrawler
+		}
+	}
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return PlatformIOS
+	case strings.Contains(ua, "android"):
+		return PlatformAndroid
+	default:
+		return PlatformDesktop
+	}
+}
+
+/*
+OpenTarget is where a shared link opens: DeepLink in the app, WebURL everywhere else.
+DeepLink is empty when the app has no page for the link, the website is opened then.
+*/
+
+type OpenTarget struct {
+	DeepLink string
+	WebURL   string
+}
+
+/*
+Returns the targets of a shared web URL. The deeplink comes from the same conversion as CreateDeepLink,
+the web URL is the shared one. Only URLs of the site (the rules' webBaseURL) are opened, so the service
+can't be used to redirect anywhere.
+*/
+
+func (l *ConverterService) OpenWebURL(webURL string) (OpenTarget, error) {
+	if err := l.Rules().checkWebURL(webURL); err != nil {
+		return OpenTarget{}, err
+	}
+	requestLink, attribution := ExtractAttribution(webURL)
+	result, err := l.ConvertDeepLink(requestLink)
+	if err != nil {
+		return OpenTarget{}, err
+	}
+	target := OpenTarget{WebURL: webURL}
+	if !result.Fallback {
+		target.DeepLink = attribution.AppendTo(result.Output)
+	}
+	return target, nil
+}
+
+/*
+Returns the targets of a short code's stored mapping, link.ErrNotFound when no mapping has the code.
+Like OpenWebURL only the site's and the app's links are opened, ErrForeignLink is returned for other mappings.
+*/
+
+func (l *ConverterService) OpenCode(code string) (OpenTarget, error) {
+	stored, err := l.ResolveCode(code)
+	if err != nil {
+		return OpenTarget{}, err
+	}
+	return OpenTarget{DeepLink: stored.Deeplink, WebURL: stored.WebUrl}, nil
+}
+
+var ErrForeignLink = errors.New("Link is not a link of the site or the app.")
+
+/*
+ForeignLinkError is returned for a web URL which isn't on the site or a deeplink which doesn't open the app.
+*/
+
+type ForeignLinkError struct {
+	Message string
+}
+
+func (e *ForeignLinkError) Error() string {
+	return e.Message
+}
+
+func (e *ForeignLinkError) Is(target error) bool {
+	return target == ErrForeignLink
+}
+
+/*
+Web URLs should be http(s) links of the rules' webBaseURL host.
+*/
+
+func (rs *RuleSet) checkWebURL(webURL string) error {
+	if _, ok := rs.parseWebURL(webURL); !ok {
+		return &ForeignLinkError{Message: "URL should be a link of " + rs.WebBaseURL + "."}
+	}
+	return nil
+}
+
+/*
+Deeplinks should have the scheme of the rules' deepLinkPrefix, e.g. 'ty'.
+*/
+
+func (rs *RuleSet) checkDeepLink(deepLink string) error {
+	u, err := url.Parse(deepLink)
+	prefix, _ := url.Parse(rs.DeepLinkPrefix)
+	if err != nil || u.Scheme == "" || !strings.EqualFold(u.Scheme, prefix.Scheme) {
+		return &ForeignLinkError{Message: "Deeplink should be a link of " + rs.DeepLinkPrefix + "."}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"trendyolcase/pkg/model"
+	"trendyolcase/pkg/repository/link"
+)
+
+func TestDetectPlatform(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		userAgent string
+		expected  Platform
+	}{
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 15_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.5 Mobile/15E148 Safari/604.1", PlatformIOS},
+		{"Mozilla/5.0 (iPad; CPU OS 15_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/103.0 Mobile/15E148 Safari/604.1", PlatformIOS},
+		{"Mozilla/5.0 (Linux; Android 12; SM-G991B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/103.0.0.0 Mobile Safari/537.36", PlatformAndroid},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/103.0.0.0 Safari/537.36", PlatformDesktop},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.5 Safari/605.1.15", PlatformDesktop},
+		{"Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/103.0.5060.134 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", PlatformCrawler},
+		{"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", PlatformCrawler},
+		{"WhatsApp/2.22.15.74 A", PlatformCrawler},
+		{"", PlatformDesktop},
+	}
+	for _, test := range tests {
+		assert.Equal(test.expected, DetectPlatform(test.userAgent), test.userAgent)
+	}
+}
+
+func TestOpenWebURL(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterService(link.NewMemoryRepository())
+
+	target, err := c.OpenWebURL("https://www.trendyol.com/casio/saat-p-1925865?utm_source=sms")
+	assert.Nil(err)
+	assert.Equal(OpenTarget{DeepLink: "ty://?Page=Product&ContentId=1925865&utm_source=sms", WebURL: "https://www.trendyol.com/casio/saat-p-1925865?utm_source=sms"}, target)
+
+	// The app has no page for it, the website opens.
+	target, err = c.OpenWebURL("https://www.trendyol.com/kampanyalar")
+	assert.Nil(err)
+	assert.Equal(OpenTarget{WebURL: "https://www.trendyol.com/kampanyalar"}, target)
+
+	for _, webURL := range []string{"https://example.com/casio/saat-p-1925865", "javascript:alert(1)", "//www.trendyol.com/sepet"} {
+		_, err = c.OpenWebURL(webURL)
+		assert.NotNil(err, webURL)
+	}
+}
+
+func TestOpenCode(t *testing.T) {
+	assert := assert.New(t)
+	repository := link.NewMemoryRepository()
+	c := NewConverterService(repository)
+
+	short, err := c.ShortenWebURL("https://www.trendyol.com/casio/saat-p-1925865", Attribution{}, "")
+	assert.Nil(err)
+	target, err := c.OpenCode(short.Code)
+	assert.Nil(err)
+	assert.Equal(OpenTarget{DeepLink: "ty://?Page=Product&ContentId=1925865", WebURL: "https://www.trendyol.com/casio/saat-p-1925865"}, target)
+	_, err = c.OpenCode("zzzzzzz")
+	assert.True(errors.Is(err, link.ErrNotFound))
+
+	// Mappings of an off-site web URL or a deeplink of another scheme aren't opened.
+	for code, stored := range map[string]model.Link{
+		"evil-weburl":   {WebUrl: "https://evil.example/x", Deeplink: "ty://?Page=Home"},
+		"evil-deeplink": {WebUrl: "https://www.trendyol.com/sr?q=saat", Deeplink: "javascript:alert(1)"},
+	} {
+		_, err = repository.GetOrCreate(model.Mapping{Link: stored})
+		assert.Nil(err)
+		_, err = repository.AssignCode(stored.WebUrl, code)
+		assert.Nil(err)
+		_, err = c.OpenCode(code)
+		assert.True(errors.Is(err, ErrForeignLink), code)
+	}
+}
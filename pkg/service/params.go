@@ -0,0 +1,92 @@
+package service
+
+import "strings"
+
+/*
+UnknownParamPolicy decides what happens to query parameters that are not in a page type's allow-list.
+*/
+
+type UnknownParamPolicy string
+
+const (
+	DropUnknownParams   UnknownParamPolicy = "drop"
+	PassUnknownParams   UnknownParamPolicy = "pass"
+	RejectUnknownParams UnknownParamPolicy = "reject"
+)
+
+/*
+Policy per direction. An empty policy drops unknown parameters.
+*/
+
+type UnknownParamsRule struct {
+	ToDeepLink UnknownParamPolicy `yaml:"toDeepLink"`
+	ToWebURL   UnknownParamPolicy `yaml:"toWebURL"`
+}
+
+func (p UnknownParamPolicy) valid() bool {
+	switch p {
+	case "", DropUnknownParams, PassUnknownParams, RejectUnknownParams:
+		return true
+	}
+	return false
+}
+
+/*
+Maps raw query pieces through the allow-list. Allowed parameters are renamed to the target side and
+come first, in allow-list order. Unknown parameters are dropped, passed with their original name or
+reject the whole link depending on the policy. A known parameter without value also rejects the link.
+The values of allowed parameters are recorded in t with their incoming names.
+*/
+
+func mapQueryParams(pieces []string, params []ParamMapping, toDeepLink bool, policy UnknownParamPolicy, t *Trace) ([]string, bool) {
+	values := map[string]string{}
+	var unknown []string
+	for _, piece := range pieces {
+		key := queryPieceKey(piece)
+		if _, ok := findParam(params, key, toDeepLink); !ok {
+			unknown = append(unknown, piece)
+			continue
+		}
+		if _, seen := values[key]; !seen {
+			values[key] = strings.TrimPrefix(piece[len(key):], "=")
+		}
+	}
+
+	var mapped []string
+	for _, param := range params {
+		from, to := param.App, param.Web
+		if toDeepLink {
+			from, to = param.Web, param.App
+		}
+		value, ok := values[from]
+		if !ok {
+			continue
+		}
+		if value == "" {
+			t.fail(ReasonEmptyParam, "Parameter "+from+" has no value.")
+			return nil, false
+		}
+		t.field(from, value)
+		mapped = append(mapped, to+"="+value)
+	}
+
+	switch policy {
+	case RejectUnknownParams:
+		if len(unknown) > 0 {
+			t.fail(ReasonRejectedParam, "Parameter "+queryPieceKey(unknown[0])+" is not allowed on this page type.")
+			return nil, false
+		}
+	case PassUnknownParams:
+		mapped = append(mapped, unknown...)
+	}
+	return mapped, true
+}
+
+func findParam(params []ParamMapping, key string, web bool) (ParamMapping, bool) {
+	for _, param := range params {
+		if (web && param.Web == key) || (!web && param.App == key) {
+			return param, true
+		}
+	}
+	return ParamMapping{}, false
+}
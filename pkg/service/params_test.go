@@ -0,0 +1,60 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestQueryParamsAllowList(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterService(nil)
+
+	links := []struct {
+		testWebURL       string
+		expectedDeepLink string
+	}{
+		{"https://www.trendyol.com/casio/saat-p-1?sizeId=42&boutiqueId=7", "ty://?Page=Product&ContentId=1&CampaignId=7&SizeId=42"},
+		{"https://www.trendyol.com/casio/saat-p-1?v=2&boutiqueId=7", "ty://?Page=Product&ContentId=1&CampaignId=7"},
+		{"https://www.trendyol.com/casio/saat-p-1?v=2", "ty://?Page=Product&ContentId=1"},
+		{"https://www.trendyol.com/casio/saat-p-1?sizeId=", "ty://?Page=Home"},
+		{"https://www.trendyol.com/erkek-t-shirt-x-g2-c73?v=2", "ty://?Page=Category&CategoryId=73&Gender=2&v=2"},
+		{"https://www.trendyol.com/sr?q=elbise&v=2", "ty://?Page=Home"},
+	}
+	for _, v := range links {
+		actualDeepLink, _ := c.CreateDeepLink(v.testWebURL)
+		assert.Equal(v.expectedDeepLink, actualDeepLink, "Should be %s", v.expectedDeepLink)
+	}
+
+	actualWebURL, _ := c.CreateWebURL("ty://?Page=Product&ContentId=1&SizeId=42&V=2&CampaignId=7")
+	assert.Equal("https://www.trendyol.com/brand/name-p-1?boutiqueId=7&sizeId=42", actualWebURL)
+}
+
+func TestUnknownParamPolicies(t *testing.T) {
+	assert := assert.New(t)
+
+	policies := []struct {
+		policy           string
+		expectedDeepLink string
+		expectedWebURL   string
+	}{
+		{"drop", "ty://?Page=Product&ContentId=1&CampaignId=7", "https://www.trendyol.com/brand/name-p-1?boutiqueId=7"},
+		{"pass", "ty://?Page=Product&ContentId=1&CampaignId=7&v=2", "https://www.trendyol.com/brand/name-p-1?boutiqueId=7&v=2"},
+		{"reject", "ty://?Page=Home", "https://www.trendyol.com"},
+	}
+	for _, v := range policies {
+		rules := strings.Replace(string(defaultRulesFile), "    toDeepLink: drop\n    toWebURL: drop", "    toDeepLink: "+v.policy+"\n    toWebURL: "+v.policy, 1)
+		rs, err := ParseRuleSet([]byte(rules))
+		assert.Nil(err)
+		c := NewConverterService(nil)
+		c.ActiveRules.Store(rs)
+
+		actualDeepLink, _ := c.CreateDeepLink("https://www.trendyol.com/casio/saat-p-1?v=2&boutiqueId=7")
+		assert.Equal(v.expectedDeepLink, actualDeepLink, "Policy %s", v.policy)
+		actualWebURL, _ := c.CreateWebURL("ty://?Page=Product&ContentId=1&v=2&CampaignId=7")
+		assert.Equal(v.expectedWebURL, actualWebURL, "Policy %s", v.policy)
+	}
+
+	_, err := ParseRuleSet([]byte(strings.Replace(string(defaultRulesFile), "toDeepLink: drop", "toDeepLink: keep", 1)))
+	assert.NotNil(err)
+}
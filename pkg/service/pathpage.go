@@ -0,0 +1,184 @@
+package service
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+/*
+Converts a URL recognized by its path (category, brand, merchant...) to a deeplink.
+Named groups of the path pattern become deeplink parameters in the order of the rule's path params.
+Query parameters (price ranges, brands, sort order...) go through the rule's allow-list and unknown parameter policy.
+*/
+
+func (rs *RuleSet) ConvertPathPageToDeepLink(p *PathPageRule, requestLink string) string {
+	return rs.pathPageToDeepLink(p, requestLink, nil)
+}
+
+func (rs *RuleSet) pathPageToDeepLink(p *PathPageRule, requestLink string, t *Trace) string {
+	u, err := url.Parse(requestLink)
+	if err != nil {
+		t.fail(ReasonUnparsable, "URL can't be parsed: "+err.Error()+".")
+		return rs.Fallback.DeepLink
+	}
+	match := p.pattern.FindStringSubmatch(u.Path)
+	if match == nil {
+		t.fail(ReasonPathMismatch, "Path doesn't match the "+p.Name+" path pattern.")
+		return rs.Fallback.DeepLink
+	}
+	values := map[string]string{}
+	for i, name := range p.pattern.SubexpNames() {
+		if name != "" {
+			values[name] = match[i]
+		}
+	}
+
+	responseDeepLink := rs.deepLinkBase(p.Page)
+	for _, param := range p.PathParams {
+		t.field(param, values[param])
+		if values[param] != "" {
+			responseDeepLink = responseDeepLink + "&" + param + "=" + values[param]
+		}
+	}
+	pieces := queryPiecesWithoutFold(u.RawQuery, p.PathParams...)
+	filters, ok := mapQueryParams(pieces, p.QueryParams, true, p.UnknownParams.ToDeepLink, t)
+	if !ok {
+		return rs.Fallback.DeepLink
+	}
+	return appendRawQuery(responseDeepLink, "&", filters)
+}
+
+/*
+Converts a deeplink of a page recognized by its path back to the URL built from the rule's web path.
+A parameter without value, or values that don't produce a path matching the rule's pattern, end up on the home page.
+*/
+
+func (rs *RuleSet) ConvertPathPageToURL(p *PathPageRule, requestLink string) string {
+	return rs.pathPageToURL(p, requestLink, nil)
+}
+
+func (rs *RuleSet) pathPageToURL(p *PathPageRule, requestLink string, t *Trace) string {
+	u, err := url.Parse(requestLink)
+	if err != nil {
+		t.fail(ReasonUnparsable, "Deeplink can't be parsed: "+err.Error()+".")
+		return rs.Fallback.WebURL
+	}
+	q, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		t.fail(ReasonUnparsable, "Deeplink parameters can't be parsed: "+err.Error()+".")
+		return rs.Fallback.WebURL
+	}
+	values := map[string]string{}
+	for _, param := range p.PathParams {
+		if q.Has(param) && q.Get(param) == "" {
+			t.fail(ReasonEmptyParam, "Parameter "+param+" has no value.")
+			return rs.Fallback.WebURL
+		}
+		values[param] = q.Get(param)
+		t.field(param, values[param])
+	}
+
+	path := fillWebPath(p.WebPath, values)
+	if !p.pattern.MatchString(path) {
+		t.fail(ReasonInvalidValue, "Path '"+path+"' built from the deeplink doesn't match the "+p.Name+" path pattern.")
+		return rs.Fallback.WebURL
+	}
+	pieces := queryPiecesWithout(u.RawQuery, append([]string{rs.PageKey}, p.PathParams...)...)
+	filters, ok := mapQueryParams(pieces, p.QueryParams, false, p.UnknownParams.ToWebURL, t)
+	if !ok {
+		return rs.Fallback.WebURL
+	}
+	return appendRawQuery(rs.WebBaseURL+path, "?", filters)
+}
+
+var optionalWebPathPart = regexp.MustCompile(`\[([^\]]*)\]`)
+
+/*
+Fills the '{Param}' placeholders of a web path. '[...]' parts are left out when one of their params has no value.
+*/
+
+func fillWebPath(webPath string, values map[string]string) string {
+	fill := func(s string) string {
+		return webPathPlaceholder.ReplaceAllStringFunc(s, func(placeholder string) string {
+			return values[strings.Trim(placeholder, "{}")]
+		})
+	}
+	webPath = optionalWebPathPart.ReplaceAllStringFunc(webPath, func(part string) string {
+		part = strings.Trim(part, "[]")
+		for _, m := range webPathPlaceholder.FindAllStringSubmatch(part, -1) {
+			if values[m[1]] == "" {
+				return ""
+			}
+		}
+		return fill(part)
+	})
+	return fill(webPath)
+}
+
+/*
+Splits a raw query into its 'key=value' pieces without decoding or reordering them.
+*/
+
+func queryPieces(rawQuery string) []string {
+	var pieces []string
+	for _, piece := range strings.Split(rawQuery, "&") {
+		if piece != "" {
+			pieces = append(pieces, piece)
+		}
+	}
+	return pieces
+}
+
+func queryPieceKey(piece string) string {
+	if idx := strings.Index(piece, "="); idx != -1 {
+		return piece[:idx]
+	}
+	return piece
+}
+
+/*
+Same as queryPieces but drops the pieces whose key is one of the given keys.
+*/
+
+func queryPiecesWithout(rawQuery string, keys ...string) []string {
+	return filterQueryPieces(rawQuery, keys, func(a, b string) bool { return a == b })
+}
+
+/*
+Same as queryPiecesWithout but keys are compared case-insensitively, so 'merchantId' in a
+storefront URL is dropped when the merchant id is already taken from the path.
+*/
+
+func queryPiecesWithoutFold(rawQuery string, keys ...string) []string {
+	return filterQueryPieces(rawQuery, keys, strings.EqualFold)
+}
+
+func filterQueryPieces(rawQuery string, keys []string, equal func(a, b string) bool) []string {
+	var pieces []string
+	for _, piece := range queryPieces(rawQuery) {
+		key := queryPieceKey(piece)
+		skip := false
+		for _, k := range keys {
+			if equal(key, k) {
+				skip = true
+				break
+			}
+		}
+		if !skip {
+			pieces = append(pieces, piece)
+		}
+	}
+	return pieces
+}
+
+/*
+Appends query pieces to link, the first one with the given separator and the rest with '&'.
+*/
+
+func appendRawQuery(link string, separator string, pieces []string) string {
+	if len(pieces) == 0 {
+		return link
+	}
+	return link + separator + strings.Join(pieces, "&")
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestConvertCategoryPageToDeepLink(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterService(nil)
+
+	webURLs := []struct {
+		testWebURL       string
+		expectedDeepLink string
+	}{
+		{"https://www.trendyol.com/erkek-t-shirt-x-g2-c73", "ty://?Page=Category&CategoryId=73&Gender=2"},
+		{"https://www.trendyol.com/elektronik-x-c104024", "ty://?Page=Category&CategoryId=104024"},
+		{"https://www.trendyol.com/erkek-t-shirt-x-g2-c73?prc=100-200&wb=101,102&sst=PRICE_BY_ASC", "ty://?Page=Category&CategoryId=73&Gender=2&prc=100-200&wb=101,102&sst=PRICE_BY_ASC"},
+		{"https://www.trendyol.com/erkek-t-shirt-x-g2-c", "ty://?Page=Home"},
+		{"https://www.trendyol.com/erkek-t-shirt-x-gx-c73", "ty://?Page=Home"},
+	}
+	for _, v := range webURLs {
+		actualDeepLink, _ := c.CreateDeepLink(v.testWebURL)
+		assert.Equal(v.expectedDeepLink, actualDeepLink, "Should be %s", v.expectedDeepLink)
+	}
+}
+
+func TestConvertCategoryPageToURL(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterService(nil)
+
+	deepLinks := []struct {
+		testDeepLink string
+		expectedURL  string
+	}{
+		{"ty://?Page=Category&CategoryId=73&Gender=2", "https://www.trendyol.com/category-x-g2-c73"},
+		{"ty://?Page=Category&CategoryId=104024", "https://www.trendyol.com/category-x-c104024"},
+		{"ty://?Page=Category&CategoryId=73&Gender=2&prc=100-200&wb=101,102&sst=PRICE_BY_ASC", "https://www.trendyol.com/category-x-g2-c73?prc=100-200&wb=101,102&sst=PRICE_BY_ASC"},
+		{"ty://?Page=Category&CategoryId=", "https://www.trendyol.com"},
+		{"ty://?Page=Category&CategoryId=73&Gender=", "https://www.trendyol.com"},
+		{"ty://?Page=Category&Gender=2", "https://www.trendyol.com"},
+	}
+	for _, v := range deepLinks {
+		actualWebURL, _ := c.CreateWebURL(v.testDeepLink)
+		assert.Equal(v.expectedURL, actualWebURL, "Should be %s", v.expectedURL)
+	}
+}
+
+func TestConvertStorefrontPageToDeepLink(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterService(nil)
+
+	webURLs := []struct {
+		testWebURL       string
+		expectedDeepLink string
+	}{
+		{"https://www.trendyol.com/casio-x-b103", "ty://?Page=Brand&BrandId=103"},
+		{"https://www.trendyol.com/casio-x-b103?sst=PRICE_BY_ASC", "ty://?Page=Brand&BrandId=103&sst=PRICE_BY_ASC"},
+		{"https://www.trendyol.com/casio-x-b", "ty://?Page=Home"},
+		{"https://www.trendyol.com/magaza/casio-m-105064", "ty://?Page=Merchant&MerchantId=105064"},
+		{"https://www.trendyol.com/magaza/casio-m-105064?sst=0", "ty://?Page=Merchant&MerchantId=105064&sst=0"},
+		{"https://www.trendyol.com/magaza/casio-m-", "ty://?Page=Home"},
+		{"https://www.trendyol.com/casio-m-105064", "ty://?Page=Home"},
+	}
+	for _, v := range webURLs {
+		actualDeepLink, _ := c.CreateDeepLink(v.testWebURL)
+		assert.Equal(v.expectedDeepLink, actualDeepLink, "Should be %s", v.expectedDeepLink)
+	}
+}
+
+func TestConvertStorefrontPageToURL(t *testing.T) {
+	assert := assert.New(t)
+	c := NewConverterService(nil)
+
+	deepLinks := []struct {
+		testDeepLink string
+		expectedURL  string
+	}{
+		{"ty://?Page=Brand&BrandId=103", "https://www.trendyol.com/brand-x-b103"},
+		{"ty://?Page=Brand&BrandId=103&sst=PRICE_BY_ASC", "https://www.trendyol.com/brand-x-b103?sst=PRICE_BY_ASC"},
+		{"ty://?Page=Brand&BrandId=", "https://www.trendyol.com"},
+		{"ty://?Page=Merchant&MerchantId=105064", "https://www.trendyol.com/magaza/name-m-105064"},
+		{"ty://?Page=Merchant&MerchantId=105064&sst=0", "https://www.trendyol.com/magaza/name-m-105064?sst=0"},
+		{"ty://?Page=Merchant&MerchantId=", "https://www.trendyol.com"},
+		{"ty://?Page=Merchant&MerchantId=abc", "https://www.trendyol.com"},
+	}
+	for _, v := range deepLinks {
+		actualWebURL, _ := c.CreateWebURL(v.testDeepLink)
+		assert.Equal(v.expectedURL, actualWebURL, "Should be %s", v.expectedURL)
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ActiveRuleSet holds the rule set used by ConverterService. It is shared by every copy of the service
+and swapped atomically, so a request always converts with one complete rule set.
+*/
+
+type ActiveRuleSet struct {
+	value atomic.Value
+}
+
+func NewActiveRuleSet(rs *RuleSet) *ActiveRuleSet {
+	a := &ActiveRuleSet{}
+	a.Store(rs)
+	return a
+}
+
+func (a *ActiveRuleSet) Load() *RuleSet {
+	return a.value.Load().(*RuleSet)
+}
+
+func (a *ActiveRuleSet) Store(rs *RuleSet) {
+	a.value.Store(rs)
+}
+
+/*
+RuleReloader loads the rules file again on request. An invalid file is rejected and the rules in use are kept.
+*/
+
+type RuleReloader struct {
+	path    string
+	active  *ActiveRuleSet
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+func NewRuleReloader(path string, active *ActiveRuleSet) *RuleReloader {
+	r := &RuleReloader{path: path, active: active}
+	if info, err := os.Stat(path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	return r
+}
+
+func (r *RuleReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	rs, err := LoadRuleSet(r.path)
+	if err != nil {
+		log.Printf("Conversion rules are not reloaded, version %s stays active: %s", r.active.Load().Version, err)
+		return err
+	}
+	previous := r.active.Load().Version
+	r.active.Store(rs)
+	log.Printf("Conversion rules reloaded from %s. Version %s replaced version %s.", r.path, rs.Version, previous)
+	return nil
+}
+
+/*
+Reloads the rules every time a signal (SIGHUP) is received on signals.
+*/
+
+func (r *RuleReloader) WatchSignals(signals <-chan os.Signal) {
+	for range signals {
+		_ = r.Reload()
+	}
+}
+
+/*
+Checks the modification time of the rules file every interval and reloads the rules when it changes.
+*/
+
+func (r *RuleReloader) WatchFile(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if r.changed() {
+				_ = r.Reload()
+			}
+		}
+	}
+}
+
+func (r *RuleReloader) changed() bool {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !info.ModTime().Equal(r.modTime)
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRuleReloader(t *testing.T) {
+	assert := assert.New(t)
+
+	path := writeTestFile(t, "rules.yaml", string(defaultRulesFile))
+	c := NewConverterService(nil)
+	reloader := NewRuleReloader(path, c.ActiveRules)
+
+	rules := strings.Replace(string(defaultRulesFile), `version: "1"`, `version: "2"`, 1)
+	rules = strings.Replace(rules, "app: CampaignId", "app: BoutiqueId", 1)
+	assert.Nil(os.WriteFile(path, []byte(rules), 0600))
+	assert.True(reloader.changed())
+	assert.Nil(reloader.Reload())
+	assert.False(reloader.changed())
+
+	assert.Equal("2", c.RulesVersion())
+	actualDeepLink, _ := c.CreateDeepLink("https://www.trendyol.com/casio/saat-p-1?boutiqueId=2")
+	assert.Equal("ty://?Page=Product&ContentId=1&BoutiqueId=2", actualDeepLink)
+
+	// Invalid files are rejected and the active rules are kept.
+	assert.Nil(os.WriteFile(path, []byte(`version: ""`), 0600))
+	assert.NotNil(reloader.Reload())
+	assert.Equal("2", c.RulesVersion())
+
+	// Copies of the service share the active rules, snapshots keep theirs.
+	copied := c
+	snapshot := c.Snapshot()
+	assert.Nil(os.WriteFile(path, defaultRulesFile, 0600))
+	assert.Nil(reloader.Reload())
+	assert.Equal("1", copied.RulesVersion())
+	assert.Equal("2", snapshot.RulesVersion())
+	actualDeepLink, _ = snapshot.CreateDeepLink("https://www.trendyol.com/casio/saat-p-1?boutiqueId=2")
+	assert.Equal("ty://?Page=Product&ContentId=1&BoutiqueId=2", actualDeepLink)
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	ProductPageRule = "product"
+	SearchPageRule  = "search"
+	StaticPageRule  = "static"
+)
+
+/*
+ConversionRule describes one page type. MatchWebURL and MatchDeepLink decide whether the rule
+handles the incoming link, ToDeepLink and ToWebURL do the actual conversion.
+TraceToDeepLink and TraceToWebURL are optional, they convert the same way and record the extracted
+fields and the fallback reason for explain mode.
+*/
+
+type ConversionRule struct {
+	Name            string
+	MatchWebURL     func(webURL string) bool
+	MatchDeepLink   func(deepLink string) bool
+	ToDeepLink      func(webURL string) string
+	ToWebURL        func(deepLink string) string
+	TraceToDeepLink func(webURL string, t *Trace) string
+	TraceToWebURL   func(deepLink string, t *Trace) string
+}
+
+func (r ConversionRule) traceToDeepLink(webURL string, t *Trace) string {
+	if r.TraceToDeepLink != nil {
+		return r.TraceToDeepLink(webURL, t)
+	}
+	return r.ToDeepLink(webURL)
+}
+
+func (r ConversionRule) traceToWebURL(deepLink string, t *Trace) string {
+	if r.TraceToWebURL != nil {
+		return r.TraceToWebURL(deepLink, t)
+	}
+	return r.ToWebURL(deepLink)
+}
+
+/*
+RuleRegistry keeps the registered page types in registration order.
+The first rule whose matcher accepts the link wins.
+*/
+
+type RuleRegistry struct {
+	rules []ConversionRule
+}
+
+func NewRuleRegistry() *RuleRegistry {
+	return &RuleRegistry{}
+}
+
+/*
+Registry with the page types of the default rule set.
+*/
+
+func DefaultRuleRegistry() *RuleRegistry {
+	return DefaultRuleSet().registry
+}
+
+func (r *RuleRegistry) Register(rule ConversionRule) {
+	r.rules = append(r.rules, rule)
+}
+
+func (r *RuleRegistry) Rules() []ConversionRule {
+	return r.rules
+}
+
+func (r *RuleRegistry) MatchWebURL(webURL string) (ConversionRule, bool) {
+	for _, rule := range r.rules {
+		if rule.MatchWebURL != nil && rule.MatchWebURL(webURL) {
+			return rule, true
+		}
+	}
+	return ConversionRule{}, false
+}
+
+func (r *RuleRegistry) MatchDeepLink(deepLink string) (ConversionRule, bool) {
+	for _, rule := range r.rules {
+		if rule.MatchDeepLink != nil && rule.MatchDeepLink(deepLink) {
+			return rule, true
+		}
+	}
+	return ConversionRule{}, false
+}
+
+/*
+Product detail pages, e.g. 'https://www.trendyol.com/casio/saat-p-1925865' <-> 'ty://?Page=Product&ContentId=1925865'.
+*/
+
+func (rs *RuleSet) productRule() ConversionRule {
+	productPageDeepLinkBase := rs.deepLinkBase(rs.Product.Page) + "&" + rs.Product.IDParam + "="
+	pathPattern := regexp.MustCompile(rs.Product.pathPattern())
+	return ConversionRule{
+		Name: ProductPageRule,
+		MatchWebURL: func(webURL string) bool {
+			return rs.webPathMatches(webURL, pathPattern)
+		},
+		MatchDeepLink: func(deepLink string) bool {
+			return strings.HasPrefix(deepLink, productPageDeepLinkBase)
+		},
+		ToDeepLink:      rs.ConvertProductDetailPageToDeepLink,
+		ToWebURL:        rs.ConvertProductDetailPageToURL,
+		TraceToDeepLink: rs.productPageToDeepLink,
+		TraceToWebURL:   rs.productPageToURL,
+	}
+}
+
+/*
+Search pages, e.g. 'https://www.trendyol.com/sr?q=elbise' <-> 'ty://?Page=Search&Query=elbise'.
+*/
+
+func (rs *RuleSet) searchRule() ConversionRule {
+	trendyolSearchPage := rs.WebBaseURL + rs.Search.WebPath + "?" + rs.Search.QueryParam.Web + "="
+	searchPageDeepLinkBase := rs.deepLinkBase(rs.Search.Page) + "&" + rs.Search.QueryParam.App + "="
+	return ConversionRule{
+		Name: SearchPageRule,
+		MatchWebURL: func(webURL string) bool {
+			return strings.HasPrefix(webURL, trendyolSearchPage)
+		},
+		MatchDeepLink: func(deepLink string) bool {
+			return strings.HasPrefix(deepLink, searchPageDeepLinkBase)
+		},
+		ToDeepLink:      rs.ConvertSearchPageToDeepLink,
+		ToWebURL:        rs.ConvertSearchPageToURL,
+		TraceToDeepLink: rs.searchPageToDeepLink,
+		TraceToWebURL:   rs.searchPageToURL,
+	}
+}
+
+/*
+Page types recognized by their path, e.g. category, brand and merchant pages.
+*/
+
+func (rs *RuleSet) pathPageRule(p *PathPageRule) ConversionRule {
+	deepLinkBase := rs.deepLinkBase(p.Page) + "&"
+	return ConversionRule{
+		Name: p.Name,
+		MatchWebURL: func(webURL string) bool {
+			return rs.webPathMatches(webURL, p.pattern)
+		},
+		MatchDeepLink: func(deepLink string) bool {
+			return strings.HasPrefix(deepLink, deepLinkBase)
+		},
+		ToDeepLink: func(webURL string) string {
+			return rs.ConvertPathPageToDeepLink(p, webURL)
+		},
+		ToWebURL: func(deepLink string) string {
+			return rs.ConvertPathPageToURL(p, deepLink)
+		},
+		TraceToDeepLink: func(webURL string, t *Trace) string {
+			return rs.pathPageToDeepLink(p, webURL, t)
+		},
+		TraceToWebURL: func(deepLink string, t *Trace) string {
+			return rs.pathPageToURL(p, deepLink, t)
+		},
+	}
+}
+
+/*
+Static pages like favorites, orders and basket, looked up in the static page table.
+*/
+
+func (rs *RuleSet) staticPageRule() ConversionRule {
+	return ConversionRule{
+		Name: StaticPageRule,
+		MatchWebURL: func(webURL string) bool {
+			_, ok := rs.staticPageForWebURL(webURL)
+			return ok
+		},
+		MatchDeepLink: func(deepLink string) bool {
+			_, ok := rs.staticPathForDeepLink(deepLink)
+			return ok
+		},
+		ToDeepLink: func(webURL string) string {
+			return rs.staticPageToDeepLink(webURL, nil)
+		},
+		ToWebURL: func(deepLink string) string {
+			return rs.staticPageToURL(deepLink, nil)
+		},
+		TraceToDeepLink: rs.staticPageToDeepLink,
+		TraceToWebURL:   rs.staticPageToURL,
+	}
+}
+
+func (rs *RuleSet) staticPageToDeepLink(webURL string, t *Trace) string {
+	page, ok := rs.staticPageForWebURL(webURL)
+	if !ok {
+		t.fail(ReasonPathMismatch, "Path is not in the static page table.")
+		return rs.Fallback.DeepLink
+	}
+	t.field(rs.PageKey, page)
+	return rs.deepLinkBase(page)
+}
+
+func (rs *RuleSet) staticPageToURL(deepLink string, t *Trace) string {
+	path, ok := rs.staticPathForDeepLink(deepLink)
+	if !ok {
+		t.fail(ReasonPathMismatch, "Page is not in the static page table.")
+		return rs.Fallback.WebURL
+	}
+	return rs.WebBaseURL + path
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestRegisterConversionRule(t *testing.T) {
+	assert := assert.New(t)
+
+	c := NewConverterService(nil)
+	c.Rules().Register(ConversionRule{
+		Name: "addresses",
+		MatchWebURL: func(webURL string) bool {
+			return strings.HasSuffix(webURL, "/Hesabim/Adreslerim")
+		},
+		MatchDeepLink: func(deepLink string) bool {
+			return deepLink == "ty://?Page=Addresses"
+		},
+		ToDeepLink: func(webURL string) string { return "ty://?Page=Addresses" },
+		ToWebURL:   func(deepLink string) string { return "https://www.trendyol.com/Hesabim/Adreslerim" },
+	})
+
+	actualDeepLink, _ := c.CreateDeepLink("https://www.trendyol.com/Hesabim/Adreslerim")
+	assert.Equal("ty://?Page=Addresses", actualDeepLink)
+	actualWebURL, _ := c.CreateWebURL("ty://?Page=Addresses")
+	assert.Equal("https://www.trendyol.com/Hesabim/Adreslerim", actualWebURL)
+
+	// Built-in rules still win for the page types they handle.
+	actualDeepLink, _ = c.CreateDeepLink("https://www.trendyol.com/sr?q=elbise")
+	assert.Equal("ty://?Page=Search&Query=elbise", actualDeepLink)
+}
+
+func TestDefaultRuleRegistryOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	rule, ok := DefaultRuleRegistry().MatchWebURL("https://www.trendyol.com/erkek-p-1-x-c73")
+	assert.True(ok)
+	assert.Equal(ProductPageRule, rule.Name)
+	// Only the path makes a product page, the separator in a search query doesn't.
+	rule, ok = DefaultRuleRegistry().MatchWebURL("https://www.trendyol.com/sr?q=a-p-1")
+	assert.True(ok)
+	assert.Equal(SearchPageRule, rule.Name)
+
+	_, ok = DefaultRuleRegistry().MatchDeepLink("ty://?Page=Addresses")
+	assert.False(ok)
+}
+
+func TestMatchWebURLHost(t *testing.T) {
+	assert := assert.New(t)
+	registry := DefaultRuleRegistry()
+
+	for _, webURL := range []string{
+		"https://www.trendyol.com.evil.com/x-p-1",
+		"https://www.trendyol.com.evil.com/erkek-t-shirt-x-c73",
+		"https://www.trendyol.com.evil.com/sepet",
+		"https://www.trendyol.com@evil.com/sepet",
+		"ftp://www.trendyol.com/x-p-1",
+	} {
+		_, ok := registry.MatchWebURL(webURL)
+		assert.False(ok, webURL)
+	}
+	for _, webURL := range []string{"https://www.trendyol.com/x-p-1", "https://WWW.TRENDYOL.COM/sepet", "http://www.trendyol.com/erkek-t-shirt-x-c73"} {
+		_, ok := registry.MatchWebURL(webURL)
+		assert.True(ok, webURL)
+	}
+}
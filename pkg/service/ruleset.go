@@ -0,0 +1,416 @@
+package service
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+//go:embed rules.yaml
+var defaultRulesFile []byte
+
+/*
+Rule set used by the package level Convert... functions. Nothing is registered on it.
+*/
+
+var defaultRules = DefaultRuleSet()
+
+/*
+RuleSet holds every constant the converters use, loaded from a rules file (see rules.yaml).
+*/
+
+type RuleSet struct {
+	Version        string         `yaml:"version"`
+	WebBaseURL     string         `yaml:"webBaseURL"`
+	DeepLinkPrefix string         `yaml:"deepLinkPrefix"`
+	PageKey        string         `yaml:"pageKey"`
+	Fallback       FallbackRule   `yaml:"fallback"`
+	Android        AndroidApp     `yaml:"android"`
+	IOS            IOSApp         `yaml:"ios"`
+	Product        ProductRule    `yaml:"product"`
+	Search         SearchRule     `yaml:"search"`
+	PathPages      []PathPageRule `yaml:"pathPages"`
+	StaticPages    []StaticPage   `yaml:"staticPages"`
+
+	staticPages *StaticPageTable
+	registry    *RuleRegistry
+}
+
+type FallbackRule struct {
+	DeepLink string `yaml:"deepLink"`
+	WebURL   string `yaml:"webURL"`
+}
+
+/*
+AndroidApp is the app opening the deeplinks on Android. Package is needed for intent:// URLs,
+SHA256CertFingerprints of the signing certificates for App Links (assetlinks.json).
+*/
+
+type AndroidApp struct {
+	Package                string   `yaml:"package"`
+	SHA256CertFingerprints []string `yaml:"sha256CertFingerprints"`
+}
+
+/*
+IOSApp is the app opening the web URLs as Universal Links, AppIDs are '<team id>.<bundle id>'.
+*/
+
+type IOSApp struct {
+	AppIDs []string `yaml:"appIDs"`
+}
+
+var (
+	androidPackagePattern  = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(\.[A-Za-z][A-Za-z0-9_]*)+$`)
+	certFingerprintPattern = regexp.MustCompile(`^[0-9A-F]{2}(:[0-9A-F]{2}){31}$`)
+	iosAppIDPattern        = regexp.MustCompile(`^[A-Z0-9]{10}\.[A-Za-z0-9.-]+$`)
+)
+
+/*
+ParamMapping renames a query parameter between the web URL and the deeplink, e.g. boutiqueId <-> CampaignId.
+*/
+
+type ParamMapping struct {
+	Web string `yaml:"web"`
+	App string `yaml:"app"`
+}
+
+type ProductRule struct {
+	Page           string            `yaml:"page"`
+	PathSeparator  string            `yaml:"pathSeparator"`
+	IDParam        string            `yaml:"idParam"`
+	DefaultWebPath string            `yaml:"defaultWebPath"`
+	QueryParams    []ParamMapping    `yaml:"queryParams"`
+	UnknownParams  UnknownParamsRule `yaml:"unknownParams"`
+}
+
+/*
+Product pages are web paths containing the path separator, e.g. '/casio/saat-p-1925865'.
+*/
+
+func (r ProductRule) pathPattern() string {
+	return regexp.QuoteMeta(r.PathSeparator)
+}
+
+type SearchRule struct {
+	Page             string            `yaml:"page"`
+	WebPath          string            `yaml:"webPath"`
+	QueryParam       ParamMapping      `yaml:"queryParam"`
+	EscapeCharacters string            `yaml:"escapeCharacters"`
+	QueryParams      []ParamMapping    `yaml:"queryParams"`
+	UnknownParams    UnknownParamsRule `yaml:"unknownParams"`
+}
+
+type PathPageRule struct {
+	Name          string            `yaml:"name"`
+	Page          string            `yaml:"page"`
+	PathPattern   string            `yaml:"pathPattern"`
+	PathParams    []string          `yaml:"pathParams"`
+	WebPath       string            `yaml:"webPath"`
+	QueryParams   []ParamMapping    `yaml:"queryParams"`
+	UnknownParams UnknownParamsRule `yaml:"unknownParams"`
+
+	pattern *regexp.Regexp
+}
+
+/*
+Rule set parsed from the embedded rules.yaml. Every call returns a new rule set,
+so rules registered on one don't leak into another.
+*/
+
+func DefaultRuleSet() *RuleSet {
+	rs, err := ParseRuleSet(defaultRulesFile)
+	if err != nil {
+		panic("Embedded rules.yaml is invalid: " + err.Error())
+	}
+	return rs
+}
+
+func LoadRuleSet(path string) (*RuleSet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := ParseRuleSet(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rs, nil
+}
+
+/*
+Parses and validates a rules file. Unknown fields are rejected so that typos don't silently
+fall back to empty values.
+*/
+
+func ParseRuleSet(b []byte) (*RuleSet, error) {
+	rs := &RuleSet{}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(rs); err != nil {
+		return nil, fmt.Errorf("Rules file could not be parsed: %w", err)
+	}
+	if err := rs.validate(); err != nil {
+		return nil, err
+	}
+	rs.registry = rs.builtInRegistry()
+	return rs, nil
+}
+
+func invalidRule(field string, problem string) error {
+	return fmt.Errorf("Rules file is invalid: %s %s.", field, problem)
+}
+
+func (rs *RuleSet) validate() error {
+	if rs.Version == "" {
+		return invalidRule("version", "should not be empty")
+	}
+	u, err := url.Parse(rs.WebBaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" {
+		return invalidRule("webBaseURL", "should be an http(s) URL without path")
+	}
+	if !strings.HasSuffix(rs.DeepLinkPrefix, "?") || len(rs.DeepLinkPrefix) == 1 {
+		return invalidRule("deepLinkPrefix", "should look like 'ty://?'")
+	}
+	if rs.PageKey == "" {
+		return invalidRule("pageKey", "should not be empty")
+	}
+	if rs.Fallback.DeepLink == "" || rs.Fallback.WebURL == "" {
+		return invalidRule("fallback", "should have deepLink and webURL")
+	}
+	if rs.Android.Package != "" && !androidPackagePattern.MatchString(rs.Android.Package) {
+		return invalidRule("android.package", "should be an application id like 'com.example.app'")
+	}
+	for i, fingerprint := range rs.Android.SHA256CertFingerprints {
+		if rs.Android.Package == "" || !certFingerprintPattern.MatchString(fingerprint) {
+			return invalidRule(fmt.Sprintf("android.sha256CertFingerprints[%d]", i), "should be an uppercase SHA-256 fingerprint like '14:6D:...' of the android.package")
+		}
+	}
+	for i, appID := range rs.IOS.AppIDs {
+		if !iosAppIDPattern.MatchString(appID) {
+			return invalidRule(fmt.Sprintf("ios.appIDs[%d]", i), "should be '<team id>.<bundle id>'")
+		}
+	}
+
+	pages := map[string]string{}
+	usePage := func(field string, page string) error {
+		if page == "" {
+			return invalidRule(field, "should not be empty")
+		}
+		if other, ok := pages[page]; ok {
+			return invalidRule(field, "uses page '"+page+"' which is already used by "+other)
+		}
+		pages[page] = field
+		return nil
+	}
+
+	if err := usePage("product.page", rs.Product.Page); err != nil {
+		return err
+	}
+	if rs.Product.PathSeparator == "" || rs.Product.IDParam == "" {
+		return invalidRule("product", "should have pathSeparator and idParam")
+	}
+	if !strings.HasPrefix(rs.Product.DefaultWebPath, "/") {
+		return invalidRule("product.defaultWebPath", "should start with '/'")
+	}
+	if err := validateQueryParams("product", rs.Product.QueryParams, rs.Product.UnknownParams, rs.PageKey, rs.Product.IDParam); err != nil {
+		return err
+	}
+
+	if err := usePage("search.page", rs.Search.Page); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(rs.Search.WebPath, "/") {
+		return invalidRule("search.webPath", "should start with '/'")
+	}
+	if err := validateParamMappings("search.queryParam", []ParamMapping{rs.Search.QueryParam}); err != nil {
+		return err
+	}
+	if err := validateQueryParams("search", append([]ParamMapping{rs.Search.QueryParam}, rs.Search.QueryParams...), rs.Search.UnknownParams, rs.PageKey); err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+	for i := range rs.PathPages {
+		p := &rs.PathPages[i]
+		field := fmt.Sprintf("pathPages[%d]", i)
+		if p.Name == "" || names[p.Name] {
+			return invalidRule(field+".name", "should be set and unique")
+		}
+		names[p.Name] = true
+		if err := usePage(field+".page", p.Page); err != nil {
+			return err
+		}
+		if err := p.compile(field); err != nil {
+			return err
+		}
+		if err := validateQueryParams(field, p.QueryParams, p.UnknownParams, append([]string{rs.PageKey}, p.PathParams...)...); err != nil {
+			return err
+		}
+	}
+
+	for i, s := range rs.StaticPages {
+		if err := usePage(fmt.Sprintf("staticPages[%d].page", i), s.Page); err != nil {
+			return err
+		}
+	}
+	staticPages, err := NewStaticPageTable(rs.StaticPages)
+	if err != nil {
+		return invalidRule("staticPages", "is not valid: "+err.Error())
+	}
+	rs.staticPages = staticPages
+	return nil
+}
+
+func validateParamMappings(field string, params []ParamMapping) error {
+	web := map[string]bool{}
+	app := map[string]bool{}
+	for _, p := range params {
+		if p.Web == "" || p.App == "" {
+			return invalidRule(field, "should have both web and app names")
+		}
+		if web[p.Web] || app[p.App] {
+			return invalidRule(field, "maps '"+p.Web+"' <-> '"+p.App+"' more than once")
+		}
+		web[p.Web] = true
+		app[p.App] = true
+	}
+	return nil
+}
+
+/*
+Checks the allow-list of a page type and its unknown parameter policy. Parameters the page type
+already uses for itself (content id, path params...) can't be in the allow-list.
+*/
+
+func validateQueryParams(field string, params []ParamMapping, unknown UnknownParamsRule, reserved ...string) error {
+	if err := validateParamMappings(field+".queryParams", params); err != nil {
+		return err
+	}
+	for _, param := range params {
+		for _, r := range reserved {
+			if param.Web == r || param.App == r {
+				return invalidRule(field+".queryParams", "can't use '"+r+"', it is already used by the page")
+			}
+		}
+	}
+	if !unknown.ToDeepLink.valid() || !unknown.ToWebURL.valid() {
+		return invalidRule(field+".unknownParams", "should be one of drop, pass or reject")
+	}
+	return nil
+}
+
+var webPathPlaceholder = regexp.MustCompile(`\{(\w+)\}`)
+
+/*
+Checks that the pattern compiles, that its named groups are exactly the path params
+and that the web path uses every path param.
+*/
+
+func (p *PathPageRule) compile(field string) error {
+	pattern, err := regexp.Compile(p.PathPattern)
+	if err != nil {
+		return invalidRule(field+".pathPattern", "does not compile: "+err.Error())
+	}
+	if len(p.PathParams) == 0 {
+		return invalidRule(field+".pathParams", "should not be empty")
+	}
+	groups := map[string]bool{}
+	for _, name := range pattern.SubexpNames() {
+		if name != "" {
+			groups[name] = true
+		}
+	}
+	placeholders := map[string]bool{}
+	for _, m := range webPathPlaceholder.FindAllStringSubmatch(p.WebPath, -1) {
+		placeholders[m[1]] = true
+	}
+	for _, param := range p.PathParams {
+		if !groups[param] {
+			return invalidRule(field+".pathPattern", "has no named group for param '"+param+"'")
+		}
+		if !placeholders[param] {
+			return invalidRule(field+".webPath", "has no '{"+param+"}' placeholder")
+		}
+		delete(groups, param)
+		delete(placeholders, param)
+	}
+	for name := range groups {
+		return invalidRule(field+".pathPattern", "has group '"+name+"' which is not in pathParams")
+	}
+	for name := range placeholders {
+		return invalidRule(field+".webPath", "has placeholder '{"+name+"}' which is not in pathParams")
+	}
+	if !strings.HasPrefix(p.WebPath, "/") {
+		return invalidRule(field+".webPath", "should start with '/'")
+	}
+	p.pattern = pattern
+	return nil
+}
+
+/*
+Deeplink of a page without parameters, e.g. 'ty://?Page=Product'.
+*/
+
+func (rs *RuleSet) deepLinkBase(page string) string {
+	return rs.DeepLinkPrefix + rs.PageKey + "=" + page
+}
+
+/*
+Registry with the page types of the rule set. Product is registered first so that paths with the product
+path separator are handled as product pages, whatever else they match.
+*/
+
+func (rs *RuleSet) builtInRegistry() *RuleRegistry {
+	r := NewRuleRegistry()
+	r.Register(rs.productRule())
+	r.Register(rs.searchRule())
+	for i := range rs.PathPages {
+		r.Register(rs.pathPageRule(&rs.PathPages[i]))
+	}
+	r.Register(rs.staticPageRule())
+	return r
+}
+
+func (rs *RuleSet) Register(rule ConversionRule) {
+	rs.registry.Register(rule)
+}
+
+func (rs *RuleSet) Rules() []ConversionRule {
+	return rs.registry.Rules()
+}
+
+func (rs *RuleSet) MatchWebURL(webURL string) (ConversionRule, bool) {
+	return rs.registry.MatchWebURL(webURL)
+}
+
+func (rs *RuleSet) MatchDeepLink(deepLink string) (ConversionRule, bool) {
+	return rs.registry.MatchDeepLink(deepLink)
+}
+
+/*
+Parses webURL, ok is false unless it is an http(s) link of the rules' webBaseURL host. The host is compared
+rather than the prefix, 'https://www.trendyol.com.evil.com' starts with the base URL too.
+*/
+
+func (rs *RuleSet) parseWebURL(webURL string) (u *url.URL, ok bool) {
+	u, err := url.Parse(webURL)
+	base, _ := url.Parse(rs.WebBaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !strings.EqualFold(u.Host, base.Host) {
+		return nil, false
+	}
+	return u, true
+}
+
+/*
+Reports whether webURL is a URL of the site whose path matches pattern.
+*/
+
+func (rs *RuleSet) webPathMatches(webURL string, pattern *regexp.Regexp) bool {
+	u, ok := rs.parseWebURL(webURL)
+	return ok && pattern.MatchString(u.Path)
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestDefaultRuleSet(t *testing.T) {
+	assert := assert.New(t)
+
+	rs := DefaultRuleSet()
+	assert.Equal("1", rs.Version)
+	assert.Equal("ty://?Page=Home", rs.Fallback.DeepLink)
+	assert.Equal("https://www.trendyol.com", rs.Fallback.WebURL)
+
+	var names []string
+	for _, rule := range rs.Rules() {
+		names = append(names, rule.Name)
+	}
+	assert.Equal([]string{ProductPageRule, SearchPageRule, "category", "brand", "merchant", StaticPageRule}, names)
+}
+
+func TestParseRuleSet(t *testing.T) {
+	assert := assert.New(t)
+
+	rules := strings.Replace(string(defaultRulesFile), "app: CampaignId", "app: BoutiqueId", 1)
+	rules = strings.Replace(rules, "path: /sepet", "path: /Sepetim", 1)
+	rs, err := ParseRuleSet([]byte(rules))
+	assert.Nil(err)
+
+	c := NewConverterService(nil)
+	c.ActiveRules.Store(rs)
+
+	actualDeepLink, _ := c.CreateDeepLink("https://www.trendyol.com/casio/saat-p-1925865?boutiqueId=439892")
+	assert.Equal("ty://?Page=Product&ContentId=1925865&BoutiqueId=439892", actualDeepLink)
+	actualWebURL, _ := c.CreateWebURL("ty://?Page=Product&ContentId=1925865&BoutiqueId=439892")
+	assert.Equal("https://www.trendyol.com/brand/name-p-1925865?boutiqueId=439892", actualWebURL)
+	actualDeepLink, _ = c.CreateDeepLink("https://www.trendyol.com/sepetim/")
+	assert.Equal("ty://?Page=Basket", actualDeepLink)
+	actualWebURL, _ = c.CreateWebURL("ty://?Page=Basket")
+	assert.Equal("https://www.trendyol.com/Sepetim", actualWebURL)
+}
+
+func TestParseRuleSetValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	invalidRules := []struct {
+		old      string
+		new      string
+		expected string
+	}{
+		{`version: "1"`, `version: ""`, "version should not be empty"},
+		{"webBaseURL: https://www.trendyol.com", "webBaseURL: www.trendyol.com", "webBaseURL should be"},
+		{"    app: CampaignId", "    app: MerchantId", "product.queryParams maps"},
+		{"  page: Search", "  page: Product", "search.page uses page 'Product'"},
+		{`\d+)$'`, `\d+$'`, "pathPages[0].pathPattern does not compile"},
+		{"pathParams: [BrandId]", "pathParams: [BrandId, Gender]", "pathPages[1].pathPattern has no named group for param 'Gender'"},
+		{"webPath: '/brand-x-b{BrandId}'", "webPath: '/brand-x-b'", "pathPages[1].webPath has no '{BrandId}' placeholder"},
+		{"  - path: /sepet", "  - path: /Hesabim/Favoriler", "staticPages is not valid"},
+		{"pageKey: Page", "pageKeys: Page", "field pageKeys not found"},
+		{"package: trendyol.com", "package: trendyol", "android.package should be an application id"},
+	}
+	for _, v := range invalidRules {
+		rules := strings.Replace(string(defaultRulesFile), v.old, v.new, 1)
+		assert.NotEqual(string(defaultRulesFile), rules, "Replacement of %s should change the rules", v.old)
+		_, err := ParseRuleSet([]byte(rules))
+		if assert.NotNil(err, "Should fail with %s", v.expected) {
+			assert.Contains(err.Error(), v.expected)
+		}
+	}
+}
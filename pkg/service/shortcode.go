@@ -0,0 +1,253 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"trendyolcase/pkg/model"
+	"trendyolcase/pkg/repository/link"
+)
+
+const (
+	base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// 62^7 codes, a collision is unlikely long before the links table gets near it.
+	generatedCodeLength = 7
+	codeAttempts        = 5
+)
+
+/*
+Vanity codes are 3 to 64 letters, digits, '-' or '_' and start with a letter or digit. Generated codes are base62.
+*/
+
+var vanityCodePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{2,63}$`)
+
+/*
+First path segments of the service's own routes, they can't be codes. Compared case-insensitively.
+*/
+
+var reservedCodes = map[string]bool{
+	"getdeeplink": true,
+	"getweburl":   true,
+	"v1":          true,
+	"open":        true,
+}
+
+var ErrHasCode = errors.New("Link already has another code.")
+
+var ErrFallbackLink = errors.New("Link is converted to the fallback page, it isn't stored and can't be shortened.")
+
+/*
+HasCodeError is returned when a vanity code is asked for a mapping which already has a code, Code is that one.
+*/
+
+type HasCodeError struct {
+	Code string
+}
+
+func (e *HasCodeError) Error() string {
+	return "Link already has the code " + e.Code + "."
+}
+
+func (e *HasCodeError) Is(target error) bool {
+	return target == ErrHasCode
+}
+
+/*
+ShortLink is a stored mapping with its short code.
+*/
+
+type ShortLink struct {
+	Code     string `json:"code"`
+	WebURL   string `json:"weburl"`
+	DeepLink string `json:"deeplink"`
+}
+
+/*
+Returns a random base62 code of generatedCodeLength characters.
+*/
+
+func RandomCode() (string, error) {
+	return randomBase62(generatedCodeLength)
+}
+
+func randomBase62(length int) (string, error) {
+	code := make([]byte, 0, length)
+	buf := make([]byte, length*2)
+	for len(code) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			// 248 is the largest multiple of 62 below 256, larger bytes would favour the first letters.
+			if b < 248 && len(code) < length {
+				code = append(code, base62Alphabet[b%62])
+			}
+		}
+	}
+	return string(code), nil
+}
+
+func ValidateVanityCode(code string) error {
+	if !vanityCodePattern.MatchString(code) {
+		return errors.New("Code should be 3 to 64 letters, digits, '-' or '_' and start with a letter or digit.")
+	}
+	if reservedCodes[strings.ToLower(code)] {
+		return errors.New("Code '" + code + "' is reserved.")
+	}
+	return nil
+}
+
+/*
+Stores the web URL like GetOrCreateDeepLink and returns it with its short code. Mappings get their code when they
+are first shortened, without vanity the mapping keeps its code or gets a generated one. A vanity code is given to a mapping which has no code yet, link.ErrCodeTaken
+is returned when another mapping uses it and ErrHasCode when the mapping already has another code.
+Codes redirect to their links, so only web URLs of the site are shortened, ErrForeignLink is returned for others.
+ErrFallbackLink is returned for web URLs converted to the fallback page, they aren't stored.
+*/
+
+func (l *ConverterService) ShortenWebURL(webURL string, attribution Attribution, vanity string) (ShortLink, error) {
+	if err := l.Rules().checkWebURL(webURL); err != nil {
+		return ShortLink{}, err
+	}
+	if err := l.checkVanity(vanity, func(owner model.Link) bool { return owner.WebUrl == webURL }); err != nil {
+		return ShortLink{}, err
+	}
+	stored, _, _, err := l.getOrCreateDeepLink(webURL, attribution)
+	if err != nil {
+		return ShortLink{}, err
+	}
+	if stored.WebUrl == "" {
+		return ShortLink{}, ErrFallbackLink
+	}
+	return l.shorten(stored, vanity)
+}
+
+/*
+Same as ShortenWebURL for a deeplink, only deeplinks of the app are shortened.
+*/
+
+func (l *ConverterService) ShortenDeepLink(deepLink string, attribution Attribution, vanity string) (ShortLink, error) {
+	if err := l.Rules().checkDeepLink(deepLink); err != nil {
+		return ShortLink{}, err
+	}
+	if err := l.checkVanity(vanity, func(owner model.Link) bool { return owner.Deeplink == deepLink }); err != nil {
+		return ShortLink{}, err
+	}
+	stored, _, _, err := l.getOrCreateWebURL(deepLink, attribution)
+	if err != nil {
+		return ShortLink{}, err
+	}
+	if stored.WebUrl == "" {
+		return ShortLink{}, ErrFallbackLink
+	}
+	return l.shorten(stored, vanity)
+}
+
+/*
+A vanity code should be valid and free, unless it already belongs to the requested link (ownedByRequest).
+It is checked before the link is stored, so a taken code doesn't store the link.
+*/
+
+func (l *ConverterService) checkVanity(vanity string, ownedByRequest func(owner model.Link) bool) error {
+	if vanity == "" {
+		return nil
+	}
+	if err := ValidateVanityCode(vanity); err != nil {
+		return err
+	}
+	owner, err := l.ConverterRepository.GetByCode(vanity)
+	if err == nil && !ownedByRequest(owner) {
+		return link.ErrCodeTaken
+	}
+	if err != nil && !errors.Is(err, link.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (l *ConverterService) shorten(stored model.Link, vanity string) (ShortLink, error) {
+	var code string
+	var err error
+	if vanity == "" {
+		code, err = l.assignCode(stored.WebUrl)
+	} else {
+		code, err = l.ConverterRepository.AssignCode(stored.WebUrl, vanity)
+		if err == nil && code != vanity {
+			return ShortLink{}, &HasCodeError{Code: code}
+		}
+	}
+	if err != nil {
+		return ShortLink{}, err
+	}
+	return ShortLink{Code: code, WebURL: stored.WebUrl, DeepLink: stored.Deeplink}, nil
+}
+
+/*
+Returns the mapping's code, generating one when it has none. A generated code used by another mapping is retried.
+*/
+
+func (l *ConverterService) assignCode(webURL string) (string, error) {
+	generate := l.CodeGenerator
+	if generate == nil {
+		generate = RandomCode
+	}
+	for i := 0; i < codeAttempts; i++ {
+		code, err := generate()
+		if err != nil {
+			return "", err
+		}
+		assigned, err := l.ConverterRepository.AssignCode(webURL, code)
+		if errors.Is(err, link.ErrCodeTaken) {
+			continue
+		}
+		return assigned, err
+	}
+	return "", errors.New("No free code was found in " + strconv.Itoa(codeAttempts) + " attempts.")
+}
+
+/*
+Returns the stored link of a code, link.ErrNotFound when no mapping has it. Mappings stored by earlier versions can hold
+links of any host, ErrForeignLink is returned when the web URL or deeplink isn't the site's or the app's.
+*/
+
+func (l *ConverterService) ResolveCode(code string) (model.Link, error) {
+	stored, err := l.ConverterRepository.GetByCode(code)
+	if err != nil {
+		return model.Link{}, err
+	}
+	rules := l.Rules()
+	if err := rules.checkWebURL(stored.WebUrl); err != nil {
+		return model.Link{}, err
+	}
+	if err := rules.checkDeepLink(stored.Deeplink); err != nil {
+		return model.Link{}, err
+	}
+	return stored, nil
+}
+
+/*
+CodeRepository is implemented by the repositories which can list the mappings without a code.
+*/
+
+type CodeRepository interface {
+	MappingsWithoutCode() ([]model.Mapping, error)
+}
+
+/*
+Gives every stored mapping without a code a generated one. Returns the number of mappings coded.
+*/
+
+func (l *ConverterService) AssignMissingCodes(r CodeRepository) (int, error) {
+	mappings, err := r.MappingsWithoutCode()
+	if err != nil {
+		return 0, err
+	}
+	for i, m := range mappings {
+		if _, err := l.assignCode(m.WebUrl); err != nil {
+			return i, err
+		}
+	}
+	return len(mappings), nil
+}
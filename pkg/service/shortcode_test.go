@@ -0,0 +1,144 @@
+package service
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"trendyolcase/pkg/model"
+	"trendyolcase/pkg/repository/link"
+)
+
+func TestRandomCode(t *testing.T) {
+	assert := assert.New(t)
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		code, err := RandomCode()
+		assert.Nil(err)
+		assert.Regexp("^[0-9A-Za-z]{7}$", code)
+		assert.False(seen[code])
+		seen[code] = true
+	}
+}
+
+func TestValidateVanityCode(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		code  string
+		valid bool
+	}{
+		{"yaz-indirimi", true},
+		{"Saat_2022", true},
+		{"ab", false},
+		{"-saat", false},
+		{"saat/kol", false},
+		{"getDeepLink", false},
+		{"Open", false},
+	}
+	for _, test := range tests {
+		assert.Equal(test.valid, ValidateVanityCode(test.code) == nil, test.code)
+	}
+}
+
+func TestShortCodes(t *testing.T) {
+	assert := assert.New(t)
+	repository := link.NewMemoryRepository()
+	c := NewConverterService(repository)
+	generated := []string{"aaaaaaa", "aaaaaaa", "bbbbbbb", "ccccccc", "ddddddd", "eeeeeee"}
+	c.CodeGenerator = func() (string, error) {
+		code := generated[0]
+		generated = generated[1:]
+		return code, nil
+	}
+
+	// Conversions store mappings without a code, shortening gives them one and a generated code in use is retried.
+	_, _, err := c.GetOrCreateDeepLink("https://www.trendyol.com/sr?q=saat", Attribution{})
+	assert.Nil(err)
+	_, err = c.ResolveCode("aaaaaaa")
+	assert.True(errors.Is(err, link.ErrNotFound))
+	_, err = c.ShortenWebURL("https://www.trendyol.com/sr?q=saat", Attribution{}, "")
+	assert.Nil(err)
+	_, err = c.ShortenDeepLink("ty://?Page=Basket", Attribution{}, "")
+	assert.Nil(err)
+	stored, err := c.ResolveCode("aaaaaaa")
+	assert.Nil(err)
+	assert.Equal(model.Link{WebUrl: "https://www.trendyol.com/sr?q=saat", Deeplink: "ty://?Page=Search&Query=saat"}, stored)
+	stored, err = c.ResolveCode("bbbbbbb")
+	assert.Nil(err)
+	assert.Equal("ty://?Page=Basket", stored.Deeplink)
+	_, err = c.ResolveCode("zzzzzzz")
+	assert.True(errors.Is(err, link.ErrNotFound))
+
+	// The mapping keeps its code, the generated one (ccccccc) is not used.
+	short, err := c.ShortenWebURL("https://www.trendyol.com/sr?q=saat", Attribution{}, "")
+	assert.Nil(err)
+	assert.Equal(ShortLink{Code: "aaaaaaa", WebURL: "https://www.trendyol.com/sr?q=saat", DeepLink: "ty://?Page=Search&Query=saat"}, short)
+	_, err = c.ShortenWebURL("https://www.trendyol.com/sr?q=saat", Attribution{}, "saat")
+	assert.True(errors.Is(err, ErrHasCode))
+
+	// Mappings stored without a code get a vanity code or one from AssignMissingCodes.
+	_, err = repository.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=etek", Deeplink: "ty://?Page=Search&Query=etek"}})
+	assert.Nil(err)
+	_, err = repository.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://www.trendyol.com/sr?q=elbise", Deeplink: "ty://?Page=Search&Query=elbise"}})
+	assert.Nil(err)
+	_, err = c.ShortenDeepLink("ty://?Page=Search&Query=etek", Attribution{}, "bbbbbbb")
+	assert.True(errors.Is(err, link.ErrCodeTaken))
+	short, err = c.ShortenDeepLink("ty://?Page=Search&Query=etek", Attribution{}, "yaz-etekleri")
+	assert.Nil(err)
+	assert.Equal("yaz-etekleri", short.Code)
+	n, err := c.AssignMissingCodes(repository)
+	assert.Nil(err)
+	assert.Equal(1, n)
+	stored, err = c.ResolveCode("ddddddd")
+	assert.Nil(err)
+	assert.Equal("https://www.trendyol.com/sr?q=elbise", stored.WebUrl)
+
+	// A new link gets the vanity code instead of a generated one, asking again returns it.
+	for i := 0; i < 2; i++ {
+		short, err = c.ShortenWebURL("https://www.trendyol.com/sr?q=ayakkabi", Attribution{}, "ayakkabi")
+		assert.Nil(err)
+		assert.Equal("ayakkabi", short.Code)
+	}
+	_, err = c.ShortenWebURL("https://www.trendyol.com/sr?q=terlik", Attribution{}, "ayakkabi")
+	assert.True(errors.Is(err, link.ErrCodeTaken))
+	_, err = c.ShortenWebURL("https://www.trendyol.com/sr?q=terlik", Attribution{}, "v1")
+	assert.NotNil(err)
+
+	// Other slugs of a stored product get the stored pair and its code.
+	_, err = c.ShortenWebURL("https://www.trendyol.com/casio/saat-p-1925865", Attribution{}, "casio-saat")
+	assert.Nil(err)
+	short, err = c.ShortenWebURL("https://www.trendyol.com/brand/name-p-1925865", Attribution{}, "")
+	assert.Nil(err)
+	assert.Equal(ShortLink{Code: "casio-saat", WebURL: "https://www.trendyol.com/casio/saat-p-1925865", DeepLink: "ty://?Page=Product&ContentId=1925865"}, short)
+}
+
+func TestShortenForeignLinks(t *testing.T) {
+	assert := assert.New(t)
+	repository := link.NewMemoryRepository()
+	c := NewConverterService(repository)
+
+	for _, webURL := range []string{"https://evil.example/x", "javascript:alert(1)", "//www.trendyol.com/sepet", "ftp://www.trendyol.com/sepet"} {
+		_, err := c.ShortenWebURL(webURL, Attribution{}, "")
+		assert.True(errors.Is(err, ErrForeignLink), webURL)
+	}
+	for _, deepLink := range []string{"javascript:alert(1)", "https://evil.example/x", "?Page=Home"} {
+		_, err := c.ShortenDeepLink(deepLink, Attribution{}, "")
+		assert.True(errors.Is(err, ErrForeignLink), deepLink)
+	}
+
+	// Fallback conversions aren't stored, links of other hosts can't be shortened that way either.
+	_, err := c.ShortenWebURL("https://www.trendyol.com/kampanyalar", Attribution{}, "")
+	assert.True(errors.Is(err, ErrFallbackLink))
+	_, _, err = c.GetOrCreateDeepLink("https://evil.example/x", Attribution{})
+	assert.Nil(err)
+	stored, err := repository.GetDeepLinks([]string{"https://evil.example/x"})
+	assert.Nil(err)
+	assert.Equal(0, len(stored))
+
+	// Mappings stored by earlier versions can hold links of any host, their codes aren't resolved.
+	_, err = repository.GetOrCreate(model.Mapping{Link: model.Link{WebUrl: "https://evil.example/x", Deeplink: "ty://?Page=Home"}})
+	assert.Nil(err)
+	code, err := repository.AssignCode("https://evil.example/x", "evil")
+	assert.Nil(err)
+	_, err = c.ResolveCode(code)
+	assert.True(errors.Is(err, ErrForeignLink))
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+/*
+StaticPage maps a web path without parameters to an app page, e.g. '/sepet' <-> 'ty://?Page=Basket'.
+*/
+
+type StaticPage struct {
+	Path string `yaml:"path" json:"path"`
+	Page string `yaml:"page" json:"page"`
+}
+
+type StaticPageTable struct {
+	pages  []StaticPage
+	byPath map[string]string
+	byPage map[string]string
+}
+
+/*
+Builds the table. Paths are matched case-insensitively and without trailing slash,
+so a path or page can only be listed once.
+*/
+
+func NewStaticPageTable(pages []StaticPage) (*StaticPageTable, error) {
+	t := &StaticPageTable{
+		pages:  pages,
+		byPath: make(map[string]string, len(pages)),
+		byPage: make(map[string]string, len(pages)),
+	}
+	for _, p := range pages {
+		if !strings.HasPrefix(p.Path, "/") || p.Page == "" {
+			return nil, errors.New("Static page '" + p.Path + "' should have a path starting with '/' and a page name.")
+		}
+		key := staticPathKey(p.Path)
+		if _, ok := t.byPath[key]; ok {
+			return nil, errors.New("Static page path '" + p.Path + "' is listed more than once.")
+		}
+		if _, ok := t.byPage[p.Page]; ok {
+			return nil, errors.New("Static page '" + p.Page + "' is listed more than once.")
+		}
+		t.byPath[key] = p.Page
+		t.byPage[p.Page] = p.Path
+	}
+	return t, nil
+}
+
+func (t *StaticPageTable) Pages() []StaticPage {
+	return t.pages
+}
+
+func (t *StaticPageTable) PageForPath(path string) (string, bool) {
+	page, ok := t.byPath[staticPathKey(path)]
+	return page, ok
+}
+
+func (t *StaticPageTable) PathForPage(page string) (string, bool) {
+	path, ok := t.byPage[page]
+	return path, ok
+}
+
+func staticPathKey(path string) string {
+	return strings.ToLower(strings.TrimSuffix(path, "/"))
+}
+
+func (rs *RuleSet) staticPageForWebURL(webURL string) (string, bool) {
+	u, ok := rs.parseWebURL(webURL)
+	if !ok {
+		return "", false
+	}
+	return rs.staticPages.PageForPath(u.Path)
+}
+
+func (rs *RuleSet) staticPathForDeepLink(deepLink string) (string, bool) {
+	if !strings.HasPrefix(deepLink, rs.DeepLinkPrefix) {
+		return "", false
+	}
+	q, err := url.ParseQuery(strings.TrimPrefix(deepLink, rs.DeepLinkPrefix))
+	if err != nil {
+		return "", false
+	}
+	return rs.staticPages.PathForPage(q.Get(rs.PageKey))
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"errors"
+	"regexp/syntax"
+	"strings"
+)
+
+var ErrNoApp = errors.New("Rules have no app for this platform.")
+
+/*
+AppleAppSiteAssociation is the /.well-known/apple-app-site-association file of iOS Universal Links.
+*/
+
+type AppleAppSiteAssociation struct {
+	AppLinks AppLinks `json:"applinks"`
+}
+
+type AppLinks struct {
+	Details []AppLinksDetail `json:"details"`
+}
+
+type AppLinksDetail struct {
+	AppIDs     []string            `json:"appIDs"`
+	Components []AppLinksComponent `json:"components"`
+}
+
+/*
+AppLinksComponent matches web URLs by path ('/', '*' is any run of characters) and query parameters ('?').
+*/
+
+type AppLinksComponent struct {
+	Path          string            `json:"/"`
+	Query         map[string]string `json:"?,omitempty"`
+	CaseSensitive *bool             `json:"caseSensitive,omitempty"`
+	Comment       string            `json:"comment,omitempty"`
+}
+
+/*
+AssetLink is a statement of the /.well-known/assetlinks.json file of Android App Links.
+*/
+
+type AssetLink struct {
+	Relation []string        `json:"relation"`
+	Target   AssetLinkTarget `json:"target"`
+}
+
+type AssetLinkTarget struct {
+	Namespace              string   `json:"namespace"`
+	PackageName            string   `json:"package_name"`
+	SHA256CertFingerprints []string `json:"sha256_cert_fingerprints"`
+}
+
+/*
+Builds the Universal Links file of the current rules: the web paths of the product, search, path and static
+page types, so that iOS opens the app only for links the converter turns into app pages.
+ErrNoApp is returned when the rules have no ios.appIDs.
+*/
+
+func (l *ConverterService) AppleAppSiteAssociation() (AppleAppSiteAssociation, error) {
+	rules := l.Rules()
+	if len(rules.IOS.AppIDs) == 0 {
+		return AppleAppSiteAssociation{}, ErrNoApp
+	}
+	return AppleAppSiteAssociation{AppLinks: AppLinks{Details: []AppLinksDetail{
+		{AppIDs: rules.IOS.AppIDs, Components: rules.appLinksComponents()},
+	}}}, nil
+}
+
+/*
+Builds the App Links file of the current rules. Android takes the paths from the app's manifest, the file
+only tells which app the site trusts. ErrNoApp is returned when the rules have no android.sha256CertFingerprints.
+*/
+
+func (l *ConverterService) AssetLinks() ([]AssetLink, error) {
+	rules := l.Rules()
+	if len(rules.Android.SHA256CertFingerprints) == 0 {
+		return nil, ErrNoApp
+	}
+	return []AssetLink{{
+		Relation: []string{"delegate_permission/common.handle_all_urls"},
+		Target: AssetLinkTarget{
+			Namespace:              "android_app",
+			PackageName:            rules.Android.Package,
+			SHA256CertFingerprints: rules.Android.SHA256CertFingerprints,
+		},
+	}}, nil
+}
+
+/*
+A component lists the paths of a page type the same way its matcher recognizes them, in the order of the registry,
+so that iOS opens the app for links the converter turns into that page type. Path pages whose pattern has no glob
+and static pages with glob characters in their path are left out, those links open on the website. Search links are
+left out as well: the converter needs 'q' as the first parameter, falls back for '?' or '/' in the query and, with the
+'reject' unknownParams policy, for any other parameter, none of which a component can tell.
+*/
+
+func (rs *RuleSet) appLinksComponents() []AppLinksComponent {
+	caseInsensitive := false
+	var components []AppLinksComponent
+	if globs, ok := pathPatternGlobs(rs.Product.pathPattern()); ok {
+		for _, glob := range globs {
+			components = append(components, AppLinksComponent{Path: glob, Comment: ProductPageRule})
+		}
+	}
+	for _, p := range rs.PathPages {
+		if globs, ok := pathPatternGlobs(p.pattern.String()); ok {
+			for _, glob := range globs {
+				components = append(components, AppLinksComponent{Path: glob, Comment: p.Name})
+			}
+		}
+	}
+	for _, page := range rs.staticPages.Pages() {
+		if !strings.ContainsAny(page.Path, "*?") {
+			components = append(components, AppLinksComponent{Path: page.Path, CaseSensitive: &caseInsensitive, Comment: StaticPageRule})
+		}
+	}
+	return components
+}
+
+/*
+Most globs a path pattern is written as, each alternative or optional part doubles them.
+*/
+
+const maxPatternGlobs = 8
+
+/*
+Turns a path pattern into app links paths matching the paths it matches. Literals are kept, a character ('.', a class
+like '\d') becomes '?', repeated characters become '*' ('.*', '\d*') or '?*' ('.+', '\d+'), and alternatives and
+optional parts become one glob each. A glob can't tell digits from other characters, so '-x-b?*' also matches
+'-x-bags'. ok is false for patterns with anything else, e.g. case folding or repeated groups, and for patterns with
+more than maxPatternGlobs globs. Unanchored patterns get '*' on the open side.
+*/
+
+func pathPatternGlobs(pattern string) (globs []string, ok bool) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, false
+	}
+	anchoredStart, anchoredEnd := false, false
+	var walk func(re *syntax.Regexp, top bool) ([]string, bool)
+	walk = func(re *syntax.Regexp, top bool) ([]string, bool) {
+		switch re.Op {
+		case syntax.OpLiteral:
+			// Globs have no escapes and app links paths are case-sensitive.
+			if re.Flags&syntax.FoldCase != 0 || strings.ContainsAny(string(re.Rune), "*?") {
+				return nil, false
+			}
+			return []string{string(re.Rune)}, true
+		case syntax.OpConcat:
+			globs := []string{""}
+			for i, sub := range re.Sub {
+				if top && i == 0 && sub.Op == syntax.OpBeginText {
+					anchoredStart = true
+					continue
+				}
+				if top && i == len(re.Sub)-1 && sub.Op == syntax.OpEndText {
+					anchoredEnd = true
+					continue
+				}
+				parts, ok := walk(sub, false)
+				if !ok || len(globs)*len(parts) > maxPatternGlobs {
+					return nil, false
+				}
+				var joined []string
+				for _, glob := range globs {
+					for _, part := range parts {
+						joined = append(joined, glob+part)
+					}
+				}
+				globs = joined
+			}
+			return globs, true
+		case syntax.OpCapture:
+			return walk(re.Sub[0], false)
+		case syntax.OpAlternate, syntax.OpQuest:
+			var globs []string
+			for _, sub := range re.Sub {
+				parts, ok := walk(sub, false)
+				if !ok {
+					return nil, false
+				}
+				globs = append(globs, parts...)
+			}
+			if re.Op == syntax.OpQuest {
+				globs = append(globs, "")
+			}
+			if len(globs) > maxPatternGlobs {
+				return nil, false
+			}
+			return globs, true
+		case syntax.OpAnyChar, syntax.OpAnyCharNotNL, syntax.OpCharClass:
+			// Paths have no newlines, '.' matches any of their characters.
+			return []string{"?"}, true
+		case syntax.OpStar, syntax.OpPlus, syntax.OpRepeat:
+			if sub := re.Sub[0].Op; sub != syntax.OpAnyChar && sub != syntax.OpAnyCharNotNL && sub != syntax.OpCharClass {
+				return nil, false
+			}
+			min, max := re.Min, re.Max
+			if re.Op == syntax.OpStar {
+				min, max = 0, -1
+			} else if re.Op == syntax.OpPlus {
+				min, max = 1, -1
+			}
+			glob := strings.Repeat("?", min)
+			if max == -1 || max > min {
+				glob += "*"
+			}
+			return []string{glob}, true
+		case syntax.OpEmptyMatch:
+			return []string{""}, true
+		}
+		return nil, false
+	}
+	parts, ok := walk(re, true)
+	if !ok {
+		return nil, false
+	}
+
+	seen := map[string]bool{}
+	for _, glob := range parts {
+		if !anchoredStart {
+			glob = "*" + glob
+		}
+		if !anchoredEnd {
+			glob += "*"
+		}
+		for strings.Contains(glob, "**") {
+			glob = strings.ReplaceAll(glob, "**", "*")
+		}
+		if !seen[glob] {
+			seen[glob] = true
+			globs = append(globs, glob)
+		}
+	}
+	return globs, true
+}
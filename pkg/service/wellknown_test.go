@@ -0,0 +1,114 @@
+package service
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestPathPatternGlobs(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{`^/kampanya/(?P<Slug>.+)$`, []string{"/kampanya/?*"}},
+		{`-butik-(?P<Slug>.*)`, []string{"*-butik-*"}},
+		{`^/magaza/.*`, []string{"/magaza/*"}},
+		{`^/indirim$`, []string{"/indirim"}},
+		{`-p-`, []string{"*-p-*"}},
+		// Digits and classes become wildcards, optional parts and alternatives a glob each.
+		{`-x-(?:g(?P<Gender>\d+)-)?c(?P<CategoryId>\d+)$`, []string{"*-x-g?*-c?*", "*-x-c?*"}},
+		{`-x-b(?P<BrandId>\d+)$`, []string{"*-x-b?*"}},
+		{`^/magaza/.+-m-(?P<MerchantId>\d+)$`, []string{"/magaza/?*-m-?*"}},
+		{`^/kampanya/[a-z]+`, []string{"/kampanya/?*"}},
+		{`^/urun/\d{2,4}$`, []string{"/urun/??*"}},
+		{`^/(?:kadin|erkek)$`, []string{"/kadin", "/erkek"}},
+		// Case folding, glob characters, repeated groups and too many alternatives can't be written as globs.
+		{`(?i)^/indirim$`, nil},
+		{`^/indirim\?$`, nil},
+		{`^/(?:ab)+$`, nil},
+		{`^/(?:kadin|erkek)/(?:giyim|ayakkabi)/(?:yaz|kis)/(?:yeni|indirim)$`, nil},
+	}
+	for _, test := range tests {
+		globs, ok := pathPatternGlobs(test.pattern)
+		assert.Equal(test.expected, globs, test.pattern)
+		assert.Equal(test.expected != nil, ok, test.pattern)
+	}
+}
+
+func TestAssociationFiles(t *testing.T) {
+	assert := assert.New(t)
+	c := ConverterService{ActiveRules: NewActiveRuleSet(DefaultRuleSet())}
+	_, err := c.AppleAppSiteAssociation()
+	assert.True(errors.Is(err, ErrNoApp))
+	_, err = c.AssetLinks()
+	assert.True(errors.Is(err, ErrNoApp))
+
+	fingerprint := "14:6D:E9:83:C5:73:06:50:D8:EE:B9:95:2F:34:FC:64:16:A0:83:42:E6:1D:BE:A8:8A:04:96:B2:3F:CF:44:E5"
+	rulesFile := strings.Replace(string(defaultRulesFile), "sha256CertFingerprints: []", `sha256CertFingerprints: ["`+fingerprint+`"]`, 1)
+	rulesFile = strings.Replace(rulesFile, "appIDs: []", `appIDs: ["ABCDE12345.com.trendyol.app"]`, 1)
+	rules, err := ParseRuleSet([]byte(rulesFile))
+	assert.Nil(err)
+	c.ActiveRules.Store(rules)
+
+	aasa, err := c.AppleAppSiteAssociation()
+	assert.Nil(err)
+	assert.Equal(1, len(aasa.AppLinks.Details))
+	assert.Equal([]string{"ABCDE12345.com.trendyol.app"}, aasa.AppLinks.Details[0].AppIDs)
+	components := aasa.AppLinks.Details[0].Components
+	assert.Equal(AppLinksComponent{Path: "*-p-*", Comment: ProductPageRule}, components[0])
+	// Search links are left out, the default rules reject other parameters after the query.
+	assert.Equal(AppLinksComponent{Path: "*-x-g?*-c?*", Comment: "category"}, components[1])
+	assert.Equal(AppLinksComponent{Path: "*-x-c?*", Comment: "category"}, components[2])
+	assert.Equal(AppLinksComponent{Path: "*-x-b?*", Comment: "brand"}, components[3])
+	assert.Equal(AppLinksComponent{Path: "/magaza/?*-m-?*", Comment: "merchant"}, components[4])
+	assert.Equal(5+len(rules.StaticPages), len(components))
+	assert.Equal("/Hesabim/Favoriler", components[5].Path)
+	assert.False(*components[5].CaseSensitive)
+
+	links, err := c.AssetLinks()
+	assert.Nil(err)
+	assert.Equal("trendyol.com", links[0].Target.PackageName)
+	assert.Equal([]string{fingerprint}, links[0].Target.SHA256CertFingerprints)
+
+	for _, invalid := range []string{`appIDs: ["com.trendyol.app"]`, `sha256CertFingerprints: ["14:6d"]`} {
+		old := "appIDs: []"
+		if strings.HasPrefix(invalid, "sha256") {
+			old = "sha256CertFingerprints: []"
+		}
+		_, err = ParseRuleSet([]byte(strings.Replace(string(defaultRulesFile), old, invalid, 1)))
+		assert.NotNil(err, invalid)
+	}
+}
+
+func TestAppLinksComponentsMatchRules(t *testing.T) {
+	assert := assert.New(t)
+	rulesFile := strings.Replace(string(defaultRulesFile), "pathPages:\n", `pathPages:
+  - name: campaign
+    page: Campaign
+    pathPattern: '^/kampanya/(?P<Slug>.+)$'
+    pathParams: [Slug]
+    webPath: '/kampanya/{Slug}'
+`, 1)
+	rules, err := ParseRuleSet([]byte(rulesFile))
+	assert.Nil(err)
+	components := rules.appLinksComponents()
+	assert.Equal(AppLinksComponent{Path: "/kampanya/?*", Comment: "campaign"}, components[1])
+
+	// Every component, with sample values for its wildcards, is a link of the page type it is listed for.
+	for _, component := range components {
+		path := strings.ReplaceAll(strings.ReplaceAll(component.Path, "?", "1"), "*", "12")
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		webURL := rules.WebBaseURL + path
+		for name := range component.Query {
+			webURL += "?" + name + "=saat"
+		}
+		rule, ok := rules.MatchWebURL(webURL)
+		assert.True(ok, webURL)
+		assert.Equal(component.Comment, rule.Name, webURL)
+	}
+}